@@ -1,6 +1,9 @@
 package v1alpha1
 
 import (
+	"encoding/json"
+
+	"cuelang.org/go/cue"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kustomize_types "sigs.k8s.io/kustomize/api/types"
 )
@@ -12,8 +15,16 @@ type Cog struct {
 
 type CogSpec struct {
 	// A resource references a yaml file containing kubernetes manifests.
-	// Each resource can be a file, directory, or a URL.
-	Resource []string `json:"resource,omitempty"`
+	// Each resource can be a file, directory, glob pattern or a URL. A path within a git
+	// repository is written as git::<url>//<path>?ref=<ref>, such as
+	// git::https://github.com/org/repo//deploy?ref=v1.2.3.
+	// Directories and globs only read .yaml, .yml and .json files, and glob patterns can use **
+	// to match any number of directories, as in manifests/**/*.yaml.
+	// Resources are written as strings, or as objects to verify or authenticate http resources.
+	Resource []Resource `json:"resource,omitempty"`
+
+	// Options for reading resource directories and globs, and fetching http resources.
+	ResourceOptions ResourceOptions `json:"resourceOptions,omitempty"`
 
 	// Helm charts to render
 	Helm []HelmChart `json:"helm,omitempty"`
@@ -30,6 +41,7 @@ type HelmChart struct {
 	ReleaseName string `json:"releaseName,omitempty"`
 
 	// Helm chart repository URL. In the case of OCI, it should start with oci://
+	// A chart directory within a git repository is written as git::<url>//<path>?ref=<ref>.
 	Repository string `json:"repository,omitempty"`
 
 	// Helm chart name.
@@ -41,6 +53,12 @@ type HelmChart struct {
 	// Chart version
 	Version string `json:"version,omitempty"`
 
+	// Values files to provide to the chart when rendering, merged in order before Values.
+	// Paths are relative to the cue instance, or to the module root when prefixed with
+	// module://, or to the chart when prefixed with chart://. Prefixed paths can't refer to
+	// files outside the module or chart.
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+
 	// Values to provide to the chart when rendering.
 	Values map[string]interface{} `json:"values,omitempty"`
 
@@ -49,9 +67,126 @@ type HelmChart struct {
 
 	// This will output a namespace resource. Must be used in conjunction with Namespace.
 	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// Leave out all objects with a helm.sh/hook annotation, like helm template --no-hooks.
+	SkipHooks bool `json:"skipHooks,omitempty"`
+
+	// Leave out test hooks, which are only run by helm test.
+	SkipTests bool `json:"skipTests,omitempty"`
+
+	// Replace helm hook annotations with their Argo CD equivalents, mapping hook weights to sync
+	// waves. Hooks without an equivalent, such as tests and pre-delete hooks, are left out, so
+	// this drops test hooks even when SkipTests is false.
+	ArgoCDHooks bool `json:"argoCDHooks,omitempty"`
+
+	// Credentials and TLS settings to access the repository with.
+	Auth HelmAuth `json:"auth,omitempty"`
+
+	// Transforms the rendered manifests before they are output.
+	PostRenderer HelmPostRenderer `json:"postRenderer,omitempty"`
+}
+
+// HelmPostRenderer transforms the manifests rendered by a chart. Only one of Command or
+// Transform can be set.
+type HelmPostRenderer struct {
+	// An executable and its arguments, which is run with the rendered manifests on stdin and
+	// writes the transformed manifests to stdout, like helm's --post-renderer. An executable
+	// containing a path separator is relative to the cue instance. Commands only run when
+	// kogen is run with --allow-post-render-commands or KOGEN_ALLOW_POST_RENDER_COMMANDS.
+	Command []string `json:"command,omitempty"`
+
+	// A cue transformation applied to each rendered object. The object is unified with the #in
+	// field and replaced with #out, which can be an object, a list of objects, or null to
+	// remove it. For example:
+	//
+	//	transform: {
+	//		#in: _
+	//		#out: #in & {metadata: labels: team: "platform"}
+	//	}
+	Transform cue.Value `json:"transform,omitempty"`
+}
+
+// HelmAuth configures access to a private chart repository or OCI registry. Secrets can be set
+// from sops encrypted values. Anything not set here is read from KOGEN_HELM_AUTH_<HOST>_*
+// environment variables, helm's repositories.yaml, or the docker config for OCI registries.
+type HelmAuth struct {
+	// Username and password for basic authentication.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Bearer token.
+	Token string `json:"token,omitempty"`
+
+	// PEM encoded CA certificates to verify the server with. Relative to the cue instance.
+	CAFile string `json:"caFile,omitempty"`
+
+	// PEM encoded client certificate and key. Relative to the cue instance.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+
+	// Skip verifying the server certificate.
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// Resource is a resource written as an object. A resource written as a string is the same as
+// an object with only its url set.
+type Resource struct {
+	// URL, file, directory, glob pattern or git source of the resource.
+	URL string `json:"url"`
+
+	// Expected sha256 of an http resource, in hex. The resource is refused if its contents
+	// don't match.
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Headers sent when fetching an http resource, such as Authorization. Values can refer to
+	// secrets decrypted with sops.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Headers sent when fetching an http resource, read from the environment variable each
+	// header is mapped to.
+	HeadersFromEnv map[string]string `json:"headersFromEnv,omitempty"`
+}
+
+// UnmarshalJSON decodes a resource from either a string or an object.
+func (r *Resource) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*r = Resource{URL: url}
+		return nil
+	}
+
+	// resource has the fields of Resource without its UnmarshalJSON method.
+	type resource Resource
+	var res resource
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	*r = Resource(res)
+	return nil
+}
+
+type ResourceOptions struct {
+	// Read the files in subdirectories of resource directories too.
+	Recursive bool `json:"recursive,omitempty"`
+
+	// Glob patterns of files and directories to leave out of resource directories and globs.
+	// Patterns without a slash match names at any depth, others match paths relative to the
+	// resource directory, or to the directory before the first wildcard of a glob.
+	Exclude []string `json:"exclude,omitempty"`
+
+	// How long each attempt to fetch an http resource can take, such as 30s. Defaults to 30s.
+	Timeout string `json:"timeout,omitempty"`
+
+	// How many times fetching an http resource is retried after a network error or a 429 or
+	// 5xx response, waiting twice as long before each retry. Defaults to 3.
+	Retries *int `json:"retries,omitempty"`
 }
 
 type HelmOptions struct {
 	KubeVersion string   `json:"kubeVersion,omitempty"`
 	APIVersions []string `json:"apiVersions,omitempty"`
+
+	// A directory of manifests that the lookup template function finds objects in, as if they
+	// were in the cluster. Relative to the cue instance. When unset, lookup finds nothing.
+	LookupDir string `json:"lookupDir,omitempty"`
 }
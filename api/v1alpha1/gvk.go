@@ -10,6 +10,7 @@ var GroupVersion = schema.GroupVersion{
 }
 
 var (
-	CogGVK     = GroupVersion.WithKind("Cog")
-	ObjectsGVK = GroupVersion.WithKind("Objects")
+	CogGVK       = GroupVersion.WithKind("Cog")
+	ObjectsGVK   = GroupVersion.WithKind("Objects")
+	KustomizeGVK = GroupVersion.WithKind("Kustomize")
 )
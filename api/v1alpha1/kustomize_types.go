@@ -0,0 +1,22 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kustomize_types "sigs.k8s.io/kustomize/api/types"
+)
+
+type Kustomize struct {
+	metav1.TypeMeta `json:",inline"`
+	Spec            KustomizeSpec `json:"spec"`
+}
+
+type KustomizeSpec struct {
+	// Directory containing the kustomization to build. A local directory is relative to the cue
+	// instance, and it can only reference files within the cue module. A remote kustomization
+	// can be any URL kustomize accepts, such as
+	// https://github.com/org/repo//deploy?ref=v1.0.0, and its output is cached.
+	Path string `json:"path"`
+
+	// Patches to apply to the objects built from the kustomization.
+	Patches []kustomize_types.Patch `json:"patches,omitempty"`
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"time"
 
+	"github.com/amir-ahmad/kogen/internal/apischema"
 	"github.com/amir-ahmad/kogen/internal/build"
+	"github.com/amir-ahmad/kogen/internal/cache"
 	"github.com/amir-ahmad/kogen/internal/generator"
 	"github.com/amir-ahmad/kogen/internal/sops"
 
@@ -13,69 +16,163 @@ import (
 	"cuelang.org/go/cue/cuecontext"
 	"cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/load"
+	kustomize_types "sigs.k8s.io/kustomize/api/types"
 )
 
-type BuildCmd struct {
+// ConfigFlags are the flags needed to load generator config from cue and find the artifacts
+// it needs in the cache. They are shared by every command that reads generator config.
+type ConfigFlags struct {
 	// flags with short options
-	Chdir      string   `short:"c" help:"Change directory before running"                                                              env:"KOGEN_CHDIR,ARGOCD_ENV_CHDIR"`
-	KindFilter string   `short:"k" help:"Regular expression to filter objects by Kind. This is case insensitive and anchored with ^$." env:"KOGEN_KIND_FILTER,ARGOCD_ENV_KIND_FILTER"`
-	Package    string   `short:"p" help:"Package to load in Cue"                                                                       env:"KOGEN_PACKAGE,ARGOCD_ENV_PACKAGE"`
-	Tag        []string `short:"t" help:"Tags to pass to Cue"                                                                          env:"KOGEN_TAG,ARGOCD_ENV_TAG"`
+	Chdir   string   `short:"c" help:"Change directory before running" env:"KOGEN_CHDIR,ARGOCD_ENV_CHDIR"`
+	Package string   `short:"p" help:"Package to load in Cue"          env:"KOGEN_PACKAGE,ARGOCD_ENV_PACKAGE"`
+	Tag     []string `short:"t" help:"Tags to pass to Cue"             env:"KOGEN_TAG,ARGOCD_ENV_TAG"`
 
 	// flags without short options
-	CacheDir   string `help:"Path to store downloaded artifacts such as helm charts"                    env:"KOGEN_CACHE_DIR,ARGOCD_ENV_KOGEN_CACHE_DIR"   default:"${cache_dir}"`
-	KogenField string `help:"Top level field to find kogen components. Defaults to kogen by convention" env:"KOGEN_FIELD,ARGOCD_ENV_KOGEN_FIELD"           default:"kogen"`
-	SopsField  string `help:"Top level field to recursively find sops attribute and decode."            env:"KOGEN_SOPS_FIELD,ARGOCD_ENV_KOGEN_SOPS_FIELD" default:"secrets"`
+	CacheDir       string        `help:"Path to store downloaded artifacts such as helm charts"                                                                             env:"KOGEN_CACHE_DIR,ARGOCD_ENV_KOGEN_CACHE_DIR"             default:"${cache_dir}"`
+	KogenField     string        `help:"Top level field to find kogen components. Defaults to kogen by convention"                                                          env:"KOGEN_FIELD,ARGOCD_ENV_KOGEN_FIELD"                     default:"kogen"`
+	SopsField      string        `help:"Top level field to recursively find sops attribute and decode."                                                                     env:"KOGEN_SOPS_FIELD,ARGOCD_ENV_KOGEN_SOPS_FIELD"           default:"secrets"`
+	KustomizeField string        `help:"Top level field with a kustomization to run over the output of every generator."                                                    env:"KOGEN_KUSTOMIZE_FIELD,ARGOCD_ENV_KOGEN_KUSTOMIZE_FIELD" default:"kustomize"`
+	CacheTTL       time.Duration `help:"How long remote resources, kustomizations and git refs are used from the cache before they're revalidated. Used forever when zero." env:"KOGEN_CACHE_TTL,ARGOCD_ENV_KOGEN_CACHE_TTL"`
+	Refresh        bool          `help:"Revalidate every remote resource, kustomization and git ref in the cache, regardless of --cache-ttl."                               env:"KOGEN_REFRESH,ARGOCD_ENV_KOGEN_REFRESH"                                        xor:"refresh"`
 
 	// positional args
 	Path string `arg:"" name:"path" help:"Cue path to read generator config from" required:"" env:"KOGEN_PATH,ARGOCD_ENV_KOGEN_PATH"`
 }
 
+// LockFlags are the flags of commands that read or write the lock file.
+type LockFlags struct {
+	LockFile string `help:"Path to the lock file. When it exists, charts and remote resources must match the digests in it." env:"KOGEN_LOCK_FILE,ARGOCD_ENV_KOGEN_LOCK_FILE" default:"${lock_file}"`
+}
+
+// BuildFlags are the flags needed to build generator config, in addition to ConfigFlags.
+// They are shared by every command that renders manifests.
+type BuildFlags struct {
+	ConfigFlags `embed:""`
+	LockFlags   `embed:""`
+
+	// flags with short options
+	KindFilter string `short:"k" help:"Regular expression to filter objects by Kind. This is case insensitive and anchored with ^$." env:"KOGEN_KIND_FILTER,ARGOCD_ENV_KIND_FILTER"`
+	Jobs       int    `short:"j" help:"Number of generators to run concurrently. Defaults to the number of CPUs."                    env:"KOGEN_JOBS,ARGOCD_ENV_KOGEN_JOBS"`
+
+	// flags without short options
+	OnDuplicate string   `help:"What to do when generators produce the same object. One of error or warn, which can't be used with a top-level kustomization."                                                       env:"KOGEN_ON_DUPLICATE,ARGOCD_ENV_KOGEN_ON_DUPLICATE" default:"error" enum:"error,warn"`
+	Provenance  bool     `help:"Annotate objects with the generator and source that produced them."                                                                                                                  env:"KOGEN_PROVENANCE,ARGOCD_ENV_KOGEN_PROVENANCE"`
+	Offline     bool     `help:"Fail if any charts or remote resources are missing from the cache instead of downloading them."                                                                                      env:"KOGEN_OFFLINE,ARGOCD_ENV_KOGEN_OFFLINE"                                             xor:"refresh"`
+	Validate    bool     `help:"Validate objects against CustomResourceDefinition schemas and the Kubernetes schemas bundled with kogen for the kubeVersion of each generator, or otherwise the latest bundled one." env:"KOGEN_VALIDATE,ARGOCD_ENV_KOGEN_VALIDATE"`
+	CRD         []string `help:"File or directory of CustomResourceDefinitions to validate custom resources against."                                                                                                env:"KOGEN_CRD,ARGOCD_ENV_KOGEN_CRD"`
+
+	// Not settable from ARGOCD_ENV_, as anyone who can change an application could then run
+	// commands in the repo server.
+	AllowPostRenderCommands bool `help:"Allow helm post renderers to run commands. Commands run with kogen's permissions, so only allow them when the config is trusted." env:"KOGEN_ALLOW_POST_RENDER_COMMANDS"`
+}
+
+type BuildCmd struct {
+	BuildFlags `embed:""`
+
+	// flags with short options
+	Output string `short:"o" enum:"yaml,json,list" help:"Output format. One of yaml, json (newline-delimited) or list (a single v1/List json document)." env:"KOGEN_OUTPUT,ARGOCD_ENV_KOGEN_OUTPUT" default:"yaml"`
+
+	// flags without short options
+	OutputDir    string `help:"Write each object to a file in this directory instead of stdout. Files kogen wrote before that are no longer generated are removed." env:"KOGEN_OUTPUT_DIR"`
+	OutputLayout string `help:"Path of the file each object is written to within --output-dir."                                                                     env:"KOGEN_OUTPUT_LAYOUT" default:"${output_layout}"`
+}
+
 func (b *BuildCmd) Run() error {
-	if b.Chdir != "" {
-		if err := os.Chdir(b.Chdir); err != nil {
-			return fmt.Errorf("failed to change directory: %w", err)
-		}
+	if err := b.chdir(); err != nil {
+		return err
 	}
 
-	genInputs, err := b.readGeneratorConfig(b.Path)
+	genInputs, kustomization, err := b.readGeneratorConfig(b.Path, "")
 	if err != nil {
 		return err
 	}
 
-	options := build.BuildOptions{
+	options, err := b.buildOptions()
+	if err != nil {
+		return err
+	}
+	options.Kustomization = kustomization
+	options.OutputFormat = build.OutputFormat(b.Output)
+	options.OutputDir = b.OutputDir
+	options.OutputLayout = b.OutputLayout
+
+	return build.Run(os.Stdout, genInputs, options)
+}
+
+// chdir changes to the directory given by --chdir, if any.
+func (b *ConfigFlags) chdir() error {
+	if b.Chdir != "" {
+		if err := os.Chdir(b.Chdir); err != nil {
+			return fmt.Errorf("failed to change directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// cacheOptions converts the flags to the build.BuildOptions that find artifacts in the cache.
+func (b *ConfigFlags) cacheOptions() build.BuildOptions {
+	return build.BuildOptions{
 		CacheDir: b.CacheDir,
+		CachePolicy: cache.Policy{
+			TTL:     b.CacheTTL,
+			Refresh: b.Refresh,
+		},
 	}
+}
+
+// buildOptions converts the flags to build.BuildOptions.
+func (b *BuildFlags) buildOptions() (build.BuildOptions, error) {
+	options := b.cacheOptions()
+	options.Jobs = b.Jobs
+	options.Validate = b.Validate
+	options.CRDPaths = b.CRD
+	options.Offline = b.Offline
+	options.LockFile = b.LockFile
+	options.Provenance = b.Provenance
+	options.DuplicatePolicy = build.DuplicatePolicy(b.OnDuplicate)
+	options.Warnings = os.Stderr
+	options.AllowPostRenderCommands = b.AllowPostRenderCommands
 
 	if b.KindFilter != "" {
 		kindFilter, err := regexp.Compile(fmt.Sprintf("(?i)^%s$", b.KindFilter))
 		if err != nil {
-			return fmt.Errorf("failed to compile kind filter: %w", err)
+			return build.BuildOptions{}, fmt.Errorf("failed to compile kind filter: %w", err)
 		}
 		options.KindFilter = kindFilter
 	}
 
-	return build.Run(os.Stdout, genInputs, options)
+	return options, nil
 }
 
-func (b *BuildCmd) readGeneratorConfig(loadPath string) ([]generator.GeneratorInput, error) {
+// readGeneratorConfig loads loadPath with cue and returns the generator inputs found under the
+// kogen field, and the kustomization under the kustomize field if there is one. loadPath is
+// resolved relative to dir, or the working directory when dir is empty.
+func (b *ConfigFlags) readGeneratorConfig(
+	loadPath, dir string,
+) ([]generator.GeneratorInput, *kustomize_types.Kustomization, error) {
 	ctx := cuecontext.New()
-	cfg := load.Config{Tags: b.Tag}
+	cfg := load.Config{Tags: b.Tag, Dir: dir}
 	if b.Package != "" {
 		cfg.Package = b.Package
 	}
 
+	apiSchema, err := apischema.New(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	genInputs := []generator.GeneratorInput{}
+	var kustomization *kustomize_types.Kustomization
 
 	insts := load.Instances([]string{loadPath}, &cfg)
 	for _, inst := range insts {
 		if inst.Err != nil {
-			return nil, fmt.Errorf("error when loading cue instance: %w", inst.Err)
+			return nil, nil, fmt.Errorf("error when loading cue instance: %w", inst.Err)
 		}
 
 		instanceValue := ctx.BuildInstance(inst)
 		if err := instanceValue.Err(); err != nil {
-			return nil, fmt.Errorf("failed to build cue instance: %w", formatCueError(err))
+			return nil, nil, fmt.Errorf("failed to build cue instance: %w", formatCueError(err))
 		}
 
 		instanceValue, err := sops.Inject(
@@ -85,41 +182,85 @@ func (b *BuildCmd) readGeneratorConfig(loadPath string) ([]generator.GeneratorIn
 			inst.Root,
 		)
 		if err != nil {
-			return nil, formatCueError(err)
+			return nil, nil, formatCueError(err)
 		}
 
 		kogenValue := instanceValue.LookupPath(cue.ParsePath(b.KogenField))
 		if err := kogenValue.Err(); err != nil {
-			return nil, fmt.Errorf("couldn't find generator config: %w", err)
+			return nil, nil, fmt.Errorf("couldn't find generator config: %w", err)
 		}
 
 		if err := kogenValue.Validate(cue.Concrete(true)); err != nil {
-			return nil, fmt.Errorf("when validating cue: %w", formatCueError(err))
+			return nil, nil, fmt.Errorf("when validating cue: %w", formatCueError(err))
+		}
+
+		instanceKustomization, err := readKustomization(instanceValue, b.KustomizeField, apiSchema)
+		if err != nil {
+			return nil, nil, err
+		}
+		if instanceKustomization != nil {
+			if kustomization != nil {
+				return nil, nil, fmt.Errorf("%s is defined in more than one cue instance", b.KustomizeField)
+			}
+			kustomization = instanceKustomization
 		}
 
 		iter, err := kogenValue.Fields()
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate generator config: %w", err)
+			return nil, nil, fmt.Errorf("failed to iterate generator config: %w", err)
 		}
 
 		for iter.Next() {
 			label := iter.Selector()
 			v := iter.Value()
 			if err := v.Err(); err != nil {
-				return nil, fmt.Errorf("error getting cue value for %s: %w", label, err)
+				return nil, nil, fmt.Errorf("error getting cue value for %s: %w", label, err)
 			}
 
 			var genInput generator.GeneratorInput
 
 			if err := v.Decode(&genInput); err != nil {
-				return nil, fmt.Errorf("failed to decode generator config for %s: %w", label, err)
+				return nil, nil, fmt.Errorf("failed to decode generator config for %s: %w", label, err)
+			}
+
+			// Check the config against the definition of its kind, which reports misspelled and
+			// mistyped fields with their position. Unknown kinds are reported when building.
+			if def, ok := apiSchema.Lookup(genInput.GroupVersionKind()); ok {
+				if err := v.Unify(def).Validate(cue.Concrete(true)); err != nil {
+					return nil, nil, fmt.Errorf("invalid generator config for %s: %w", label, formatCueError(err))
+				}
 			}
 
+			genInput.Label = label.Unquoted()
 			genInput.InstanceDir = inst.Dir
+			genInput.ModuleRoot = inst.Root
 			genInputs = append(genInputs, genInput)
 		}
 	}
-	return genInputs, nil
+	return genInputs, kustomization, nil
+}
+
+// readKustomization decodes the kustomization at field, which is run over the output of every
+// generator. It returns nil if the field doesn't exist.
+func readKustomization(
+	instanceValue cue.Value,
+	field string,
+	apiSchema *apischema.Schema,
+) (*kustomize_types.Kustomization, error) {
+	v := instanceValue.LookupPath(cue.ParsePath(field))
+	if !v.Exists() {
+		return nil, nil
+	}
+
+	if err := v.Unify(apiSchema.Kustomization()).Validate(cue.Concrete(true)); err != nil {
+		return nil, fmt.Errorf("invalid kustomization: %w", formatCueError(err))
+	}
+
+	var kustomization kustomize_types.Kustomization
+	if err := v.Decode(&kustomization); err != nil {
+		return nil, fmt.Errorf("failed to decode kustomization: %w", err)
+	}
+	return &kustomization, nil
 }
 
 func formatCueError(err error) error {
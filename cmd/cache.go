@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/amir-ahmad/kogen/internal/cache"
+)
+
+type CacheCmd struct {
+	List  CacheListCmd  `cmd:"" help:"List the charts, repositories and remote resources in the cache"`
+	Prune CachePruneCmd `cmd:"" help:"Remove artifacts that haven't been used recently from the cache"`
+	Clear CacheClearCmd `cmd:"" help:"Remove every artifact from the cache"`
+}
+
+// CacheFlags are the flags shared by every cache command.
+type CacheFlags struct {
+	CacheDir string `help:"Path to store downloaded artifacts such as helm charts" env:"KOGEN_CACHE_DIR,ARGOCD_ENV_KOGEN_CACHE_DIR" default:"${cache_dir}"`
+}
+
+type CacheListCmd struct {
+	CacheFlags `embed:""`
+}
+
+func (c *CacheListCmd) Run() error {
+	entries, err := cache.List(c.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tSIZE\tLAST USED\tSOURCE") //nolint:errcheck
+	var total int64
+	for _, entry := range entries {
+		fmt.Fprintf( //nolint:errcheck
+			w,
+			"%s\t%s\t%s\t%s\t%s\n",
+			entry.Kind,
+			entry.Name,
+			formatSize(entry.Size),
+			entry.LastUsed.Format(time.DateTime),
+			entry.Source,
+		)
+		total += entry.Size
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d entries, %s\n", len(entries), formatSize(total))
+	return nil
+}
+
+type CachePruneCmd struct {
+	CacheFlags `embed:""`
+
+	MaxAge time.Duration `help:"Remove artifacts that haven't been used for longer than this" default:"720h"`
+}
+
+func (c *CachePruneCmd) Run() error {
+	removed, err := cache.Prune(c.CacheDir, c.MaxAge)
+	var total int64
+	for _, entry := range removed {
+		fmt.Printf("removed %s/%s\n", entry.Kind, entry.Name)
+		total += entry.Size
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removed %d entries, %s\n", len(removed), formatSize(total))
+	return nil
+}
+
+type CacheClearCmd struct {
+	CacheFlags `embed:""`
+}
+
+func (c *CacheClearCmd) Run() error {
+	return cache.Clear(c.CacheDir)
+}
+
+// formatSize formats a number of bytes with a binary unit.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/amir-ahmad/kogen/internal/build"
+	"github.com/amir-ahmad/kogen/internal/diff"
+	"github.com/amir-ahmad/kogen/internal/git"
+)
+
+type DiffCmd struct {
+	BuildFlags `embed:""`
+
+	From     string `help:"Git revision to compare from"                                         default:"HEAD"`
+	To       string `help:"Git revision to compare to. Defaults to the working tree"`
+	FromFile string `help:"Compare from previously saved build output instead of a git revision"`
+}
+
+// Help implements kong.HelpProvider.
+func (d *DiffCmd) Help() string {
+	return "Exits with status 1 when there are differences, and 0 when there are none."
+}
+
+func (d *DiffCmd) Run() error {
+	if err := d.chdir(); err != nil {
+		return err
+	}
+
+	var from diff.Source
+	if d.FromFile != "" {
+		data, err := os.ReadFile(d.FromFile)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", d.FromFile, err)
+		}
+		from = diff.Source{Name: d.FromFile, Data: data}
+	} else {
+		data, err := d.render(d.From)
+		if err != nil {
+			return err
+		}
+		from = diff.Source{Name: d.From, Data: data}
+	}
+
+	data, err := d.render(d.To)
+	if err != nil {
+		return err
+	}
+	to := diff.Source{Name: d.To, Data: data}
+	if d.To == "" {
+		to.Name = "working tree"
+	}
+
+	result, err := diff.Objects(os.Stdout, from, to, build.DuplicatePolicy(d.OnDuplicate))
+	if err != nil {
+		return err
+	}
+
+	// Like git diff --exit-code, differences are reported with exit status 1.
+	if result.HasChanges() {
+		return exitStatus(1)
+	}
+
+	return nil
+}
+
+// render builds the path at a git revision, or in the working tree when rev is empty.
+func (d *DiffCmd) render(rev string) ([]byte, error) {
+	dir := ""
+	if rev != "" {
+		tmpDir, err := os.MkdirTemp("", "kogen-diff-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir) //nolint:errcheck
+
+		dir, err = git.Export(rev, tmpDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export git revision %s: %w", rev, err)
+		}
+	}
+
+	genInputs, kustomization, err := d.readGeneratorConfig(d.Path, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := d.buildOptions()
+	if err != nil {
+		return nil, err
+	}
+	options.Kustomization = kustomization
+
+	// The lock file is read from the revision being built.
+	if dir != "" && !filepath.IsAbs(options.LockFile) {
+		options.LockFile = filepath.Join(dir, options.LockFile)
+	}
+
+	var buf bytes.Buffer
+	if err := build.Run(&buf, genInputs, options); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/amir-ahmad/kogen/internal/build"
+)
+
+type FetchCmd struct {
+	ConfigFlags `embed:""`
+}
+
+func (f *FetchCmd) Run() error {
+	if err := f.chdir(); err != nil {
+		return err
+	}
+
+	genInputs, _, err := f.readGeneratorConfig(f.Path, "")
+	if err != nil {
+		return err
+	}
+
+	options := f.cacheOptions()
+
+	return build.Fetch(os.Stdout, genInputs, options)
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/amir-ahmad/kogen/internal/apischema"
+	"github.com/amir-ahmad/kogen/internal/cache"
+	"github.com/amir-ahmad/kogen/internal/git"
+	"github.com/amir-ahmad/kogen/internal/helm"
+	"github.com/amir-ahmad/kogen/internal/importer"
+)
+
+type ImportCmd struct {
+	Chart ImportChartCmd `cmd:"" help:"Generate a cue #Values definition from the values.schema.json or values.yaml of a helm chart"`
+	CRD   ImportCRDCmd   `cmd:"" help:"Generate cue definitions for each kind and version of CustomResourceDefinitions"              name:"crd"`
+	API   ImportAPICmd   `cmd:"" help:"Generate the cue definitions of the kogen generator kinds, such as #Cog and #Objects"         name:"api"`
+}
+
+type ImportAPICmd struct {
+	// flags with short options
+	Package string `short:"p" help:"Package of the generated cue file"     default:"kogen"`
+	Output  string `short:"o" help:"File to write to. Defaults to stdout."`
+}
+
+func (i *ImportAPICmd) Run() error {
+	src, err := apischema.Source(i.Package)
+	if err != nil {
+		return err
+	}
+
+	return writeImport(src, i.Output)
+}
+
+type ImportChartCmd struct {
+	// flags with short options
+	Package string `short:"p" help:"Package of the generated cue file"     default:"values"`
+	Output  string `short:"o" help:"File to write to. Defaults to stdout."`
+
+	// flags without short options
+	ChartName string `help:"Name of the chart in the repository. Not needed for OCI and local charts."`
+	Version   string `help:"Version of the chart. Not needed for local charts."`
+	CacheDir  string `help:"Path to store downloaded artifacts such as helm charts"                    env:"KOGEN_CACHE_DIR,ARGOCD_ENV_KOGEN_CACHE_DIR" default:"${cache_dir}"`
+
+	// positional args
+	Repository string `arg:"" name:"repository" help:"Helm chart repository URL, oci:// URL, git:: source or path to a local chart"`
+}
+
+func (i *ImportChartCmd) Run() error {
+	chart := helm.Chart{
+		Repository: i.Repository,
+		ChartName:  i.ChartName,
+		Version:    i.Version,
+	}
+
+	chartDir, err := chartPath(chart, i.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	src, err := importer.Chart(chartDir, i.Package)
+	if err != nil {
+		return err
+	}
+
+	return writeImport(src, i.Output)
+}
+
+type ImportCRDCmd struct {
+	// flags with short options
+	Package string `short:"p" help:"Package of the generated cue file"     default:"crds"`
+	Output  string `short:"o" help:"File to write to. Defaults to stdout."`
+
+	// flags without short options
+	Chart     string `help:"Helm chart repository URL, oci:// URL or path to a local chart whose crds directory to read"`
+	ChartName string `help:"Name of the chart in the repository. Not needed for OCI and local charts."`
+	Version   string `help:"Version of the chart. Not needed for local charts."`
+	CacheDir  string `help:"Path to store downloaded artifacts such as helm charts"                                      env:"KOGEN_CACHE_DIR,ARGOCD_ENV_KOGEN_CACHE_DIR" default:"${cache_dir}"`
+
+	// positional args
+	Sources []string `arg:"" optional:"" name:"source" help:"Files, directories or URLs of CustomResourceDefinitions"`
+}
+
+func (i *ImportCRDCmd) Run() error {
+	if len(i.Sources) == 0 && i.Chart == "" {
+		return fmt.Errorf("either sources or --chart must be given")
+	}
+
+	objects, err := importer.ReadObjects(i.Sources)
+	if err != nil {
+		return err
+	}
+
+	if i.Chart != "" {
+		chartDir, err := chartPath(helm.Chart{
+			Repository: i.Chart,
+			ChartName:  i.ChartName,
+			Version:    i.Version,
+		}, i.CacheDir)
+		if err != nil {
+			return err
+		}
+		chartObjects, err := importer.ChartCRDs(chartDir)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, chartObjects...)
+	}
+
+	src, err := importer.CRDs(objects, i.Package)
+	if err != nil {
+		return err
+	}
+
+	return writeImport(src, i.Output)
+}
+
+// chartPath returns the path of a local chart, or downloads a remote chart into the cache.
+func chartPath(chart helm.Chart, cacheDir string) (string, error) {
+	if git.IsSource(chart.Repository) {
+		source, err := git.ParseSource(chart.Repository)
+		if err != nil {
+			return "", err
+		}
+		chartDir, _, err := source.Checkout(filepath.Join(cacheDir, "git"), cache.Policy{})
+		if err != nil {
+			return "", fmt.Errorf("when checking out chart: %w", err)
+		}
+		return chartDir, nil
+	}
+
+	if chart.GetChartType() == helm.ChartTypeLocal {
+		return chart.Repository, nil
+	}
+
+	chartDir, err := chart.DownloadChart(filepath.Join(cacheDir, "helm"))
+	if err != nil {
+		return "", fmt.Errorf("when downloading chart: %w", err)
+	}
+	return chartDir, nil
+}
+
+// writeImport writes generated cue to output, or stdout when output is empty.
+func writeImport(src []byte, output string) error {
+	if output == "" {
+		_, err := os.Stdout.Write(src)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", output, err)
+	}
+	if err := os.WriteFile(output, src, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/amir-ahmad/kogen/internal/build"
+)
+
+type LockCmd struct {
+	ConfigFlags `embed:""`
+	LockFlags   `embed:""`
+}
+
+func (l *LockCmd) Run() error {
+	if err := l.chdir(); err != nil {
+		return err
+	}
+
+	genInputs, _, err := l.readGeneratorConfig(l.Path, "")
+	if err != nil {
+		return err
+	}
+
+	options := l.cacheOptions()
+	options.LockFile = l.LockFile
+
+	return build.Lock(os.Stdout, genInputs, options)
+}
@@ -1,18 +1,33 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/alecthomas/kong"
+	"github.com/amir-ahmad/kogen/internal/build"
+	"github.com/amir-ahmad/kogen/internal/lock"
 )
 
 type Cli struct {
 	Build   BuildCmd   `cmd:"" help:"Generate Kubernetes manifests"`
+	Cache   CacheCmd   `cmd:"" help:"Manage the charts and remote resources downloaded into the cache"`
+	Diff    DiffCmd    `cmd:"" help:"Show differences between the manifests generated at two revisions."`
+	Fetch   FetchCmd   `cmd:"" help:"Download charts and remote resources into the cache"`
+	Import  ImportCmd  `cmd:"" help:"Generate cue definitions from other schemas"`
+	Lock    LockCmd    `cmd:"" help:"Write the digests of charts and remote resources to the lock file"`
 	Version VersionCmd `cmd:"" help:"Show version information"`
 }
 
+// exitStatus is returned by commands that exit with a non-zero status without reporting an error.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
 func getCacheDir() (string, error) {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
@@ -30,8 +45,15 @@ func Execute() {
 		os.Exit(1)
 	}
 	ctx := kong.Parse(&cli, kong.Vars{
-		"cache_dir": cacheDir,
+		"cache_dir":     cacheDir,
+		"output_layout": build.DefaultOutputLayout,
+		"lock_file":     lock.DefaultPath,
 	})
 	err = ctx.Run(&cli)
+
+	var status exitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	}
 	ctx.FatalIfErrorf(err)
 }
@@ -1,7 +1,12 @@
 package test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/amir-ahmad/kogen/cmd"
@@ -28,5 +33,48 @@ func TestScripts(t *testing.T) {
 			}
 			return false, nil
 		},
+		Cmds: map[string]func(ts *testscript.TestScript, neg bool, args []string){
+			"serve": serve,
+		},
 	})
 }
+
+// serve starts an http server for the rest of the script and sets $SERVER to its url.
+//
+//	serve dir
+//
+// A request for a path serves the file at that path within dir. When there are files named
+// path.2, path.3 and so on, the nth request for the path serves the last of them up to n, so
+// that tests can change what's served between requests. Requests are never answered with 304
+// Not Modified.
+func serve(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) != 1 {
+		ts.Fatalf("usage: serve dir")
+	}
+	dir := ts.MkAbs(args[0])
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+
+		path := filepath.Join(dir, filepath.FromSlash(r.URL.Path))
+		for i := n; i > 1; i-- {
+			if _, err := os.Stat(fmt.Sprintf("%s.%d", path, i)); err == nil {
+				path = fmt.Sprintf("%s.%d", path, i)
+				break
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data) //nolint:errcheck
+	}))
+	ts.Defer(server.Close)
+	ts.Setenv("SERVER", server.URL)
+}
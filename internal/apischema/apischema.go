@@ -0,0 +1,114 @@
+// Package apischema derives cue definitions for the kogen generator kinds from their go types,
+// so that generator configs can be checked with precise cue errors before they are decoded.
+package apischema
+
+import (
+	"fmt"
+	"reflect"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kinds are the generator kinds that have a definition, named after the kind such as #Cog.
+var kinds = []struct {
+	gvk schema.GroupVersionKind
+	typ reflect.Type
+}{
+	{gvk: v1alpha1.CogGVK, typ: reflect.TypeFor[v1alpha1.Cog]()},
+	{gvk: v1alpha1.ObjectsGVK, typ: reflect.TypeFor[v1alpha1.Objects]()},
+	{gvk: v1alpha1.KustomizeGVK, typ: reflect.TypeFor[v1alpha1.Kustomize]()},
+}
+
+// shorthands are structs that can also be written as a string, which their UnmarshalJSON
+// methods accept in place of the object.
+var shorthands = map[reflect.Type]bool{
+	reflect.TypeFor[v1alpha1.Resource](): true,
+}
+
+// Schema holds the definitions of the generator kinds, built in a cue context.
+type Schema struct {
+	value cue.Value
+}
+
+// New builds the definitions of the generator kinds in ctx. Values can only be unified with
+// the definitions when they are from the same context.
+func New(ctx *cue.Context) (*Schema, error) {
+	value := ctx.BuildFile(file(""))
+	if err := value.Err(); err != nil {
+		return nil, fmt.Errorf("failed to build the kogen api schema: %w", err)
+	}
+	return &Schema{value: value}, nil
+}
+
+// Lookup returns the definition of the generator kind gvk, if there is one.
+func (s *Schema) Lookup(gvk schema.GroupVersionKind) (cue.Value, bool) {
+	for _, kind := range kinds {
+		if kind.gvk == gvk {
+			return s.value.LookupPath(cue.MakePath(cue.Def(gvk.Kind))), true
+		}
+	}
+	return cue.Value{}, false
+}
+
+// Kustomization returns the definition of a kustomization.
+func (s *Schema) Kustomization() cue.Value {
+	return s.value.LookupPath(cue.MakePath(cue.Def("Kustomization")))
+}
+
+// Source returns a cue file in package pkgName with the definitions of the generator kinds,
+// which generator configs can be unified with.
+func Source(pkgName string) ([]byte, error) {
+	src, err := format.Node(file(pkgName), format.Simplify())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format the kogen api schema: %w", err)
+	}
+	return src, nil
+}
+
+// file returns a cue file with the definitions of the generator kinds, followed by the
+// definitions of the structs they use. The package clause is left out when pkgName is empty.
+func file(pkgName string) *ast.File {
+	f := &ast.File{}
+	if pkgName != "" {
+		f.Decls = append(f.Decls, &ast.Package{Name: ast.NewIdent(pkgName)})
+	}
+
+	c := newConverter()
+	for _, kind := range kinds {
+		// The kinds take their names first, so that no struct is named like them.
+		c.taken["#"+kind.gvk.Kind] = true
+	}
+	for _, kind := range kinds {
+		decl := &ast.Field{
+			Label: ast.NewIdent("#" + kind.gvk.Kind),
+			Value: definition(c, kind.gvk, kind.typ),
+		}
+		ast.SetRelPos(decl, token.NewSection)
+		f.Decls = append(f.Decls, decl)
+	}
+	for _, decl := range c.decls {
+		ast.SetRelPos(decl, token.NewSection)
+		f.Decls = append(f.Decls, decl)
+	}
+	return f
+}
+
+// definition returns the struct of a generator kind, with its apiVersion and kind fixed.
+func definition(c *converter, gvk schema.GroupVersionKind, typ reflect.Type) ast.Expr {
+	s := &ast.StructLit{Elts: []ast.Decl{
+		&ast.Field{Label: ast.NewIdent("apiVersion"), Value: ast.NewString(gvk.GroupVersion().String())},
+		&ast.Field{Label: ast.NewIdent("kind"), Value: ast.NewString(gvk.Kind)},
+	}}
+	for _, field := range c.structFields(typ) {
+		name, _, _ := ast.LabelName(field.Label)
+		if name != "apiVersion" && name != "kind" {
+			s.Elts = append(s.Elts, field)
+		}
+	}
+	return s
+}
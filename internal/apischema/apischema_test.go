@@ -0,0 +1,159 @@
+package apischema
+
+import (
+	"reflect"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSchema_Lookup(t *testing.T) {
+	tests := map[string]struct {
+		gvk         schema.GroupVersionKind
+		config      string
+		expectError string
+	}{
+		"valid cog": {
+			gvk: v1alpha1.CogGVK,
+			config: `{
+	apiVersion: "kogen.internal/v1alpha1"
+	kind:       "Cog"
+	spec: {
+		helm: [{releaseName: "hello", values: replicas: 1}]
+		kustomize: namespace: "default"
+	}
+}`,
+		},
+		"misspelled field": {
+			gvk:         v1alpha1.CogGVK,
+			config:      `{apiVersion: "kogen.internal/v1alpha1", kind: "Cog", spec: helm: [{releasNme: "hello"}]}`,
+			expectError: "spec.helm.0.releasNme: field not allowed",
+		},
+		"mistyped field": {
+			gvk:         v1alpha1.CogGVK,
+			config:      `{apiVersion: "kogen.internal/v1alpha1", kind: "Cog", spec: resource: "a.yaml"}`,
+			expectError: `spec.resource: conflicting values "a.yaml" and [...(string|#Resource)]`,
+		},
+		"resources as strings or objects": {
+			gvk: v1alpha1.CogGVK,
+			config: `{
+	apiVersion: "kogen.internal/v1alpha1"
+	kind:       "Cog"
+	spec: resource: ["a.yaml", {url: "https://example.com/b.yaml", headers: Authorization: "token"}]
+}`,
+		},
+		"misspelled resource field": {
+			gvk:         v1alpha1.CogGVK,
+			config:      `{apiVersion: "kogen.internal/v1alpha1", kind: "Cog", spec: resource: [{url: "a.yaml", sha: "aa"}]}`,
+			expectError: "spec.resource.0.sha: field not allowed",
+		},
+		"third party field": {
+			gvk:         v1alpha1.CogGVK,
+			config:      `{apiVersion: "kogen.internal/v1alpha1", kind: "Cog", spec: kustomize: namePrefx: "a-"}`,
+			expectError: "spec.kustomize.namePrefx: field not allowed",
+		},
+		"any objects": {
+			gvk:    v1alpha1.ObjectsGVK,
+			config: `{apiVersion: "kogen.internal/v1alpha1", kind: "Objects", spec: objects: [{kind: "ConfigMap"}]}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := cuecontext.New()
+			s, err := New(ctx)
+			require.NoError(t, err)
+
+			def, ok := s.Lookup(tc.gvk)
+			require.True(t, ok)
+
+			err = ctx.CompileString(tc.config).Unify(def).Validate(cue.Concrete(true))
+			if tc.expectError != "" {
+				require.Error(t, err)
+				// Errors in disjunctions are only spelled out in the details.
+				assert.Contains(t, errors.Details(err, nil), tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSchema_LookupUnknownKind(t *testing.T) {
+	s, err := New(cuecontext.New())
+	require.NoError(t, err)
+
+	_, ok := s.Lookup(v1alpha1.GroupVersion.WithKind("Chart"))
+	assert.False(t, ok)
+}
+
+func TestConverter(t *testing.T) {
+	type inner struct {
+		Name string `json:"name"`
+	}
+	type embedded struct {
+		Embedded string `json:"embedded,omitempty"`
+	}
+	type node struct {
+		Children []node `json:"children,omitempty"`
+	}
+	type example struct {
+		embedded `json:",inline"`
+
+		Inner   inner             `json:"inner"`
+		Pointer *inner            `json:"pointer,omitempty"`
+		Labels  map[string]string `json:"labels"`
+		Values  map[string]any    `json:"values"`
+		Any     cue.Value         `json:"any"`
+		Count   uint              `json:"count"`
+		Ratio   float64           `json:"ratio"`
+		Data    []byte            `json:"data"`
+		Tree    node              `json:"tree"`
+		Skipped string            `json:"-"`
+		NoTag   bool
+		private string
+	}
+
+	c := newConverter()
+	expr := c.typeExpr(reflect.TypeFor[example]())
+	src, err := format.Node(expr)
+	require.NoError(t, err)
+	assert.Equal(t, "#example", string(src))
+
+	var defs []string
+	for _, decl := range c.decls {
+		src, err := format.Node(decl, format.Simplify())
+		require.NoError(t, err)
+		defs = append(defs, string(src))
+	}
+	assert.Equal(t, []string{
+		`#example: {
+	embedded?: string
+	inner?:    #inner
+	pointer?:  #inner
+	labels?: [string]: string
+	values?: {
+		...
+	}
+	any?:   _
+	count?: uint
+	ratio?: number
+	data?:  string
+	tree?:  #node
+	NoTag?: bool
+}`,
+		`#inner: {
+	name?: string
+}`,
+		`#node: {
+	children?: [...#node]
+}`,
+	}, defs)
+}
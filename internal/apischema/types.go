@@ -0,0 +1,152 @@
+package apischema
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/token"
+)
+
+var (
+	cueValueType        = reflect.TypeFor[cue.Value]()
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// converter converts go types to cue, with a definition for each named struct so that errors
+// refer to the definition instead of spelling out the struct.
+type converter struct {
+	names map[reflect.Type]string
+	taken map[string]bool
+	decls []ast.Decl
+}
+
+func newConverter() *converter {
+	return &converter{names: map[reflect.Type]string{}, taken: map[string]bool{}}
+}
+
+// typeExpr returns the cue type that decodes into typ the way encoding/json would.
+func (c *converter) typeExpr(typ reflect.Type) ast.Expr {
+	if typ == cueValueType {
+		return ast.NewIdent("_")
+	}
+	if shorthands[typ] {
+		return &ast.BinaryExpr{X: ast.NewIdent("string"), Op: token.OR, Y: ast.NewIdent(c.define(typ))}
+	}
+	// Types that decode themselves may accept anything, so they aren't constrained.
+	if reflect.PointerTo(typ).Implements(jsonUnmarshalerType) {
+		return ast.NewIdent("_")
+	}
+	if reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return ast.NewIdent("string")
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return c.typeExpr(typ.Elem())
+	case reflect.String:
+		return ast.NewIdent("string")
+	case reflect.Bool:
+		return ast.NewIdent("bool")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ast.NewIdent("int")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ast.NewIdent("uint")
+	case reflect.Float32, reflect.Float64:
+		return ast.NewIdent("number")
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			// encoding/json decodes byte slices from base64 strings.
+			return ast.NewIdent("string")
+		}
+		return ast.NewList(&ast.Ellipsis{Type: c.typeExpr(typ.Elem())})
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String || typ.Elem().Kind() == reflect.Interface {
+			return ast.NewStruct(&ast.Ellipsis{})
+		}
+		return ast.NewStruct(&ast.Field{
+			Label: ast.NewList(ast.NewIdent("string")),
+			Value: c.typeExpr(typ.Elem()),
+		})
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return c.structLit(typ)
+		}
+		return ast.NewIdent(c.define(typ))
+	default:
+		return ast.NewIdent("_")
+	}
+}
+
+// define adds a definition for a named struct, unless it was already added, and returns its
+// name. Structs are named after their go type, prefixed with their package name when another
+// package has a struct with the same name.
+func (c *converter) define(typ reflect.Type) string {
+	if name, ok := c.names[typ]; ok {
+		return name
+	}
+
+	name := "#" + typ.Name()
+	if c.taken[name] {
+		pkg := path.Base(typ.PkgPath())
+		name = "#" + strings.ToUpper(pkg[:1]) + pkg[1:] + typ.Name()
+	}
+	c.names[typ] = name
+	c.taken[name] = true
+
+	// The definition is added before converting its fields, so that recursive types refer to it.
+	field := &ast.Field{Label: ast.NewIdent(name)}
+	c.decls = append(c.decls, field)
+	field.Value = c.structLit(typ)
+	return name
+}
+
+// structLit returns the fields of a struct as a cue struct.
+func (c *converter) structLit(typ reflect.Type) *ast.StructLit {
+	s := &ast.StructLit{}
+	for _, field := range c.structFields(typ) {
+		s.Elts = append(s.Elts, field)
+	}
+	return s
+}
+
+// structFields returns the fields of a struct as optional cue fields, following the json tags
+// and inlining embedded structs like encoding/json does. Every field is optional as the
+// generators treat missing fields as their zero value.
+func (c *converter) structFields(typ reflect.Type) []*ast.Field {
+	var fields []*ast.Field
+	for i := range typ.NumField() {
+		sf := typ.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fieldType := sf.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if sf.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			fields = append(fields, c.structFields(fieldType)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, &ast.Field{
+			Label:      ast.NewStringLabel(name),
+			Constraint: token.OPTION,
+			Value:      c.typeExpr(sf.Type),
+		})
+	}
+	return fields
+}
@@ -4,11 +4,17 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"runtime"
+	"sync"
 
 	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"github.com/amir-ahmad/kogen/internal/cache"
 	"github.com/amir-ahmad/kogen/internal/generator"
 	cog_v1alpha1 "github.com/amir-ahmad/kogen/internal/generator/cog/v1alpha1"
+	kust_v1alpha1 "github.com/amir-ahmad/kogen/internal/generator/kustomize/v1alpha1"
 	obj_v1alpha1 "github.com/amir-ahmad/kogen/internal/generator/objects/v1alpha1"
+	"github.com/amir-ahmad/kogen/internal/lock"
+	kustomize_types "sigs.k8s.io/kustomize/api/types"
 )
 
 // BuildOptions are options to configure kogen build.
@@ -16,53 +22,263 @@ type BuildOptions struct {
 	// CacheDir is the directory to use for downloading artifacts.
 	CacheDir string
 
-	// KindFilter is a regular expression to filter objects by Kind.
+	// CachePolicy is when remote files in the cache are revalidated. It's ignored in offline
+	// mode, where cached files are always used. With a lock file, it only applies when the
+	// artifacts are verified, and the verified copies are used to generate objects.
+	CachePolicy cache.Policy
+
+	// KindFilter is a regular expression to filter objects by Kind. It's applied to the output of
+	// the kustomization, so that the kustomization sees every object.
 	KindFilter *regexp.Regexp
+
+	// Jobs is the maximum number of generators to run concurrently. Defaults to the number of
+	// CPUs when less than 1.
+	Jobs int
+
+	// OutputFormat is the format objects are written in. Defaults to OutputFormatYAML.
+	OutputFormat OutputFormat
+
+	// OutputDir is a directory to write objects to as files, instead of writing a single stream.
+	OutputDir string
+
+	// OutputLayout is the path of the file each object is written to, relative to OutputDir.
+	// See DefaultOutputLayout for the supported placeholders.
+	OutputLayout string
+
+	// DuplicatePolicy is what to do when generators produce objects with the same identity.
+	// Defaults to DuplicatePolicyError.
+	DuplicatePolicy DuplicatePolicy
+
+	// Warnings is where warnings are written. Warnings are discarded when nil.
+	Warnings io.Writer
+
+	// Offline fails the build if any remote artifacts are missing from the cache, instead of
+	// downloading them.
+	Offline bool
+
+	// LockFile is the path of the lock file. When the file exists, the digest of every remote
+	// artifact must match it.
+	LockFile string
+
+	// Provenance enables annotating every object with the generator and source that produced
+	// it.
+	Provenance bool
+
+	// AllowPostRenderCommands allows helm post renderers to run commands.
+	AllowPostRenderCommands bool
+
+	// Validate enables validating objects against CRD schemas and the Kubernetes schemas bundled
+	// with kogen for the kubeVersion of the generator that produced them.
+	Validate bool
+
+	// CRDPaths are files or directories containing CustomResourceDefinitions to validate
+	// custom resources against, in addition to those in the build output.
+	CRDPaths []string
+
+	// Kustomization is run over the combined objects of every generator, after duplicates are
+	// checked and before objects are filtered and validated. Objects aren't kustomized when nil.
+	// It can't be used with DuplicatePolicyWarn, as kustomize fails on duplicate objects.
+	Kustomization *kustomize_types.Kustomization
+}
+
+// builtObject is a generated object along with details of the generator that produced it.
+type builtObject struct {
+	generator.Object
+
+	// label is the label of the generator.
+	label string
+
+	// kubeVersion is the Kubernetes version targeted by the generator, if known.
+	kubeVersion string
 }
 
 func init() {
 	// Register GVKs with their init functions.
 	generator.Register(v1alpha1.CogGVK, cog_v1alpha1.NewGenerator)
 	generator.Register(v1alpha1.ObjectsGVK, obj_v1alpha1.NewGenerator)
+	generator.Register(v1alpha1.KustomizeGVK, kust_v1alpha1.NewGenerator)
 }
 
+// Run runs every generator and writes the objects to w, or to opts.OutputDir when set.
 func Run(w io.Writer, genInputs []generator.GeneratorInput, opts BuildOptions) error {
-	genOptions := generator.Options{
-		CacheDir: opts.CacheDir,
+	// Validate the output format before generating anything.
+	ow, err := newObjectWriter(w, opts.OutputFormat)
+	if err != nil {
+		return err
 	}
 
-	printSeparator := false
-	for _, genInput := range genInputs {
-		gen, err := generator.GetGenerator(genInput)
+	// Duplicates would be let through only for kustomize to fail on them.
+	if opts.Kustomization != nil && opts.DuplicatePolicy == DuplicatePolicyWarn {
+		return fmt.Errorf(
+			"duplicate policy %q can't be used with a top-level kustomization, which fails on duplicate objects",
+			DuplicatePolicyWarn,
+		)
+	}
+
+	generated, err := generate(genInputs, opts)
+	if err != nil {
+		return err
+	}
+
+	warnings := opts.Warnings
+	if warnings == nil {
+		warnings = io.Discard
+	}
+
+	if err := checkDuplicates(generated, opts.DuplicatePolicy, warnings); err != nil {
+		return err
+	}
+
+	if opts.Kustomization != nil {
+		generated, err = kustomizeObjects(generated, *opts.Kustomization)
 		if err != nil {
 			return err
 		}
+	}
+	objects := filterKinds(generated, opts.KindFilter)
 
-		it, err := gen.Generate(genOptions)
-		if err != nil {
+	if opts.Validate {
+		// CRDs are taken from the output before the kind filter, so that custom resources are
+		// still validated when their CRDs are filtered out.
+		if err := validateObjects(objects, generated, opts.CRDPaths); err != nil {
 			return err
 		}
+	}
 
-		for object, err := range it {
-			if err != nil {
-				return err
-			}
+	if opts.OutputDir != "" {
+		return writeOutputDir(objects, opts)
+	}
 
-			if opts.KindFilter != nil && !opts.KindFilter.MatchString(object.GetKind()) {
-				continue
-			}
+	for _, object := range objects {
+		if err := ow.Write(object); err != nil {
+			return err
+		}
+	}
 
-			// The separator needs to be printed after every object but the last.
-			if printSeparator {
-				fmt.Fprintf(w, "---\n") //nolint:errcheck
-			} else {
-				printSeparator = true
-			}
+	return ow.Close()
+}
+
+// generate runs the generators concurrently and returns their objects. Objects are returned in
+// the order of genInputs regardless of which generator finishes first, and if several generators
+// fail the error of the first one is returned.
+func generate(genInputs []generator.GeneratorInput, opts BuildOptions) ([]builtObject, error) {
+	genOptions := generator.Options{
+		CacheDir:                opts.CacheDir,
+		Provenance:              opts.Provenance,
+		AllowPostRenderCommands: opts.AllowPostRenderCommands,
+	}
+	if !opts.Offline {
+		genOptions.CachePolicy = opts.CachePolicy
+	}
+
+	gens, err := newGenerators(genInputs)
+	if err != nil {
+		return nil, err
+	}
 
-			if err := object.Output(w); err != nil {
-				return err
+	if opts.Offline {
+		if err := checkCached(gens, genInputs, genOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.LockFile != "" {
+		lockFile, err := lock.Read(opts.LockFile)
+		if err != nil {
+			return nil, err
+		}
+		if lockFile != nil {
+			if err := verifyLocked(gens, genInputs, genOptions, lockFile); err != nil {
+				return nil, err
 			}
+			// Every artifact is now in the cache and matches the lock file. Generators use
+			// those copies as they are, since revalidating them could render content that was
+			// never verified.
+			genOptions.CachePolicy = cache.Policy{}
+		}
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+
+	results := make([][]builtObject, len(gens))
+	errs := make([]error, len(gens))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, gen := range gens {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i], errs[i] = runGenerator(gen, genInputs[i].Label, genOptions)
+		})
+	}
+	wg.Wait()
+
+	var objects []builtObject
+	for i := range gens {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		objects = append(objects, results[i]...)
+	}
+	return objects, nil
+}
+
+// newGenerators initialises a generator for each input. Generators are initialised
+// sequentially as they read their cue spec.
+func newGenerators(genInputs []generator.GeneratorInput) ([]generator.Generator, error) {
+	gens := make([]generator.Generator, len(genInputs))
+	for i, genInput := range genInputs {
+		gen, err := generator.GetGenerator(genInput)
+		if err != nil {
+			return nil, err
+		}
+		gens[i] = gen
+	}
+	return gens, nil
+}
+
+// runGenerator runs a single generator and returns its objects.
+func runGenerator(gen generator.Generator, label string, genOptions generator.Options) ([]builtObject, error) {
+	var kubeVersion string
+	if kv, ok := gen.(generator.KubeVersioner); ok {
+		kubeVersion = kv.KubeVersion()
+	}
+
+	it, err := gen.Generate(genOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []builtObject
+	for object, err := range it {
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, builtObject{
+			Object:      object,
+			label:       label,
+			kubeVersion: kubeVersion,
+		})
+	}
+	return objects, nil
+}
+
+// filterKinds returns the objects whose kind matches kindFilter, or every object when it's nil.
+func filterKinds(objects []builtObject, kindFilter *regexp.Regexp) []builtObject {
+	if kindFilter == nil {
+		return objects
+	}
+
+	var filtered []builtObject
+	for _, object := range objects {
+		if kindFilter.MatchString(object.GetKind()) {
+			filtered = append(filtered, object)
 		}
 	}
-	return nil
+	return filtered
 }
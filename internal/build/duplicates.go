@@ -0,0 +1,69 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/internal/generator"
+)
+
+// DuplicatePolicy is what to do when several objects have the same identity.
+type DuplicatePolicy string
+
+const (
+	// DuplicatePolicyError fails the build.
+	DuplicatePolicyError DuplicatePolicy = "error"
+	// DuplicatePolicyWarn writes a warning and outputs every duplicate.
+	DuplicatePolicyWarn DuplicatePolicy = "warn"
+)
+
+// checkDuplicates finds objects with the same apiVersion, kind, namespace and name, and
+// reports them according to policy, naming the generators that produced each one.
+func checkDuplicates(objects []builtObject, policy DuplicatePolicy, warnings io.Writer) error {
+	switch policy {
+	case "":
+		policy = DuplicatePolicyError
+	case DuplicatePolicyError, DuplicatePolicyWarn:
+	default:
+		return fmt.Errorf("unknown duplicate policy %q", policy)
+	}
+
+	var keys []string
+	labels := map[string][]string{}
+	for _, object := range objects {
+		key := generator.ObjectKey(object)
+		if _, ok := labels[key]; !ok {
+			keys = append(keys, key)
+		}
+		labels[key] = append(labels[key], object.label)
+	}
+
+	var duplicates []string
+	for _, key := range keys {
+		if len(labels[key]) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf(
+				"%s is generated by %s",
+				key,
+				strings.Join(labels[key], ", "),
+			))
+		}
+	}
+
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	if policy == DuplicatePolicyWarn {
+		for _, duplicate := range duplicates {
+			fmt.Fprintf(warnings, "warning: duplicate object %s\n", duplicate) //nolint:errcheck
+		}
+		return nil
+	}
+
+	return fmt.Errorf(
+		"found %d duplicate objects:\n  %s",
+		len(duplicates),
+		strings.Join(duplicates, "\n  "),
+	)
+}
@@ -0,0 +1,78 @@
+package build
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newConfigMap(label, namespace, name string) builtObject {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return builtObject{Object: &store.Object{Unstructured: u}, label: label}
+}
+
+func TestCheckDuplicates(t *testing.T) {
+	tests := map[string]struct {
+		objects        []builtObject
+		policy         DuplicatePolicy
+		expectWarnings string
+		expectError    string
+	}{
+		"no duplicates": {
+			objects: []builtObject{
+				newConfigMap("a", "default", "one"),
+				newConfigMap("b", "default", "two"),
+				newConfigMap("b", "other", "one"),
+			},
+		},
+		"duplicate across generators": {
+			objects: []builtObject{
+				newConfigMap("a", "default", "one"),
+				newConfigMap("b", "default", "one"),
+			},
+			expectError: "found 1 duplicate objects:\n  v1|ConfigMap|default|one is generated by a, b",
+		},
+		"duplicate within a generator": {
+			objects: []builtObject{
+				newConfigMap("a", "", "one"),
+				newConfigMap("a", "", "one"),
+				newConfigMap("c", "", "one"),
+			},
+			policy:      DuplicatePolicyError,
+			expectError: "v1|ConfigMap||one is generated by a, a, c",
+		},
+		"warn": {
+			objects: []builtObject{
+				newConfigMap("a", "default", "one"),
+				newConfigMap("b", "default", "one"),
+			},
+			policy:         DuplicatePolicyWarn,
+			expectWarnings: "warning: duplicate object v1|ConfigMap|default|one is generated by a, b\n",
+		},
+		"unknown policy": {
+			policy:      "ignore",
+			expectError: `unknown duplicate policy "ignore"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var warnings bytes.Buffer
+			err := checkDuplicates(tc.objects, tc.policy, &warnings)
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectWarnings, warnings.String())
+		})
+	}
+}
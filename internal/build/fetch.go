@@ -0,0 +1,101 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/internal/generator"
+)
+
+// labelledArtifact is a remote artifact along with the label of the generator that needs it.
+type labelledArtifact struct {
+	generator.Artifact
+
+	label string
+}
+
+// artifacts returns the remote artifacts needed by every generator that downloads them.
+func artifacts(
+	gens []generator.Generator,
+	genInputs []generator.GeneratorInput,
+	genOptions generator.Options,
+) ([]labelledArtifact, error) {
+	var all []labelledArtifact
+	for i, gen := range gens {
+		fetcher, ok := gen.(generator.Fetcher)
+		if !ok {
+			continue
+		}
+
+		genArtifacts, err := fetcher.Artifacts(genOptions)
+		if err != nil {
+			return nil, fmt.Errorf("%s: when listing artifacts: %w", genInputs[i].Label, err)
+		}
+
+		for _, artifact := range genArtifacts {
+			all = append(all, labelledArtifact{Artifact: artifact, label: genInputs[i].Label})
+		}
+	}
+	return all, nil
+}
+
+// checkCached returns an error listing every remote artifact that is missing from the cache.
+func checkCached(
+	gens []generator.Generator,
+	genInputs []generator.GeneratorInput,
+	genOptions generator.Options,
+) error {
+	all, err := artifacts(gens, genInputs, genOptions)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, artifact := range all {
+		if !artifact.Cached {
+			missing = append(missing, fmt.Sprintf("%s: %s", artifact.label, artifact.Name))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"offline mode is enabled but %d artifacts are missing from the cache, "+
+				"run kogen fetch to download them:\n  %s",
+			len(missing),
+			strings.Join(missing, "\n  "),
+		)
+	}
+
+	return nil
+}
+
+// Fetch downloads every remote artifact needed by the generators into opts.CacheDir, so that
+// later builds can run offline. The artifacts that are downloaded are written to w.
+func Fetch(w io.Writer, genInputs []generator.GeneratorInput, opts BuildOptions) error {
+	gens, err := newGenerators(genInputs)
+	if err != nil {
+		return err
+	}
+
+	all, err := artifacts(gens, genInputs, generator.Options{
+		CacheDir:    opts.CacheDir,
+		CachePolicy: opts.CachePolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, artifact := range all {
+		if artifact.Cached {
+			continue
+		}
+
+		fmt.Fprintf(w, "fetching %s: %s\n", artifact.label, artifact.Name) //nolint:errcheck
+		if _, err := artifact.Fetch(); err != nil {
+			return fmt.Errorf("%s: when fetching %s: %w", artifact.label, artifact.Name, err)
+		}
+	}
+
+	return nil
+}
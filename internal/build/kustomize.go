@@ -0,0 +1,80 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/amir-ahmad/kogen/internal/kustomize"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kustomize_types "sigs.k8s.io/kustomize/api/types"
+)
+
+// annotationIndex holds the position of an object in the build output while it is kustomized,
+// so that the objects kustomize returns can be traced back to their generator.
+const annotationIndex = "kogen.internal/index"
+
+// kustomizeLabel is the generator label of objects created by the kustomization itself, such
+// as those from a configMapGenerator.
+const kustomizeLabel = "kustomize"
+
+// kustomizeObjects runs kustomization over the combined objects of every generator.
+func kustomizeObjects(
+	objects []builtObject,
+	kustomization kustomize_types.Kustomization,
+) ([]builtObject, error) {
+	var manifests bytes.Buffer
+	for i, object := range objects {
+		jsonBytes, err := object.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode object %s: %w", object.GetName(), err)
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(jsonBytes); err != nil {
+			return nil, fmt.Errorf("failed to decode object %s: %w", object.GetName(), err)
+		}
+		store.SetAnnotations(obj, map[string]string{annotationIndex: strconv.Itoa(i)})
+
+		if err := (&store.Object{Unstructured: obj}).Output(&manifests); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&manifests, "---\n") //nolint:errcheck
+	}
+
+	output, err := kustomize.Build(manifests.Bytes(), kustomization, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("when applying the build kustomization: %w", err)
+	}
+
+	kustomized, err := store.DecodeYaml(output)
+	if err != nil {
+		return nil, fmt.Errorf("when decoding kustomize output: %w", err)
+	}
+
+	result := make([]builtObject, 0, len(kustomized))
+	for _, obj := range kustomized {
+		built := builtObject{label: kustomizeLabel}
+
+		annotations := obj.GetAnnotations()
+		if index, ok := annotations[annotationIndex]; ok {
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 || i >= len(objects) {
+				return nil, fmt.Errorf("kustomize output has an invalid %s annotation %q", annotationIndex, index)
+			}
+			built.label = objects[i].label
+			built.kubeVersion = objects[i].kubeVersion
+
+			delete(annotations, annotationIndex)
+			if len(annotations) == 0 {
+				annotations = nil
+			}
+			obj.SetAnnotations(annotations)
+		}
+
+		built.Object = &store.Object{Unstructured: obj}
+		result = append(result, built)
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package build
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kustomize_types "sigs.k8s.io/kustomize/api/types"
+)
+
+func TestKustomizeObjects(t *testing.T) {
+	a := newConfigMap("a", "", "one")
+	a.kubeVersion = "1.30.0"
+	b := newConfigMap("b", "", "two")
+
+	objects, err := kustomizeObjects([]builtObject{a, b}, kustomize_types.Kustomization{
+		Namespace:  "apps",
+		NamePrefix: "prod-",
+		ConfigMapGenerator: []kustomize_types.ConfigMapArgs{{
+			GeneratorArgs: kustomize_types.GeneratorArgs{
+				Name: "generated",
+				KvPairSources: kustomize_types.KvPairSources{
+					LiteralSources: []string{"mode=prod"},
+				},
+				Options: &kustomize_types.GeneratorOptions{DisableNameSuffixHash: true},
+			},
+		}},
+	})
+	require.NoError(t, err)
+
+	type result struct {
+		label, kubeVersion, namespace, name string
+		annotations                         map[string]string
+	}
+	var results []result
+	for _, object := range objects {
+		results = append(results, result{
+			label:       object.label,
+			kubeVersion: object.kubeVersion,
+			namespace:   object.GetNamespace(),
+			name:        object.GetName(),
+			annotations: object.Object.(*store.Object).GetAnnotations(),
+		})
+	}
+
+	assert.ElementsMatch(t, []result{
+		{label: "a", kubeVersion: "1.30.0", namespace: "apps", name: "prod-one"},
+		{label: "b", namespace: "apps", name: "prod-two"},
+		{label: kustomizeLabel, namespace: "apps", name: "prod-generated"},
+	}, results)
+}
+
+func TestKustomizeObjects_Error(t *testing.T) {
+	_, err := kustomizeObjects([]builtObject{newConfigMap("a", "", "one")}, kustomize_types.Kustomization{
+		Resources: []string{"missing.yaml"},
+	})
+	require.ErrorContains(t, err, "when applying the build kustomization")
+}
+
+func TestRun_KustomizationWithDuplicateWarnings(t *testing.T) {
+	err := Run(&bytes.Buffer{}, nil, BuildOptions{
+		DuplicatePolicy: DuplicatePolicyWarn,
+		Kustomization:   &kustomize_types.Kustomization{NamePrefix: "prod-"},
+	})
+	require.ErrorContains(t, err, `duplicate policy "warn" can't be used with a top-level kustomization`)
+}
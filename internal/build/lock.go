@@ -0,0 +1,79 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/internal/generator"
+	"github.com/amir-ahmad/kogen/internal/lock"
+)
+
+// verifyLocked fetches every remote artifact that isn't cached, and returns an error listing
+// the artifacts whose digests don't match the lock file.
+func verifyLocked(
+	gens []generator.Generator,
+	genInputs []generator.GeneratorInput,
+	genOptions generator.Options,
+	lockFile *lock.File,
+) error {
+	all, err := artifacts(gens, genInputs, genOptions)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, artifact := range all {
+		digest, err := artifact.Fetch()
+		if err != nil {
+			return fmt.Errorf("%s: when fetching %s: %w", artifact.label, artifact.Name, err)
+		}
+
+		if err := lockFile.Verify(artifact.Name, digest); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", artifact.label, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf(
+			"lock file verification failed with %d errors:\n  %s",
+			len(errs),
+			strings.Join(errs, "\n  "),
+		)
+	}
+
+	return nil
+}
+
+// Lock fetches every remote artifact needed by the generators and writes their digests to
+// opts.LockFile, replacing its previous contents. The artifacts that are downloaded are
+// written to w.
+func Lock(w io.Writer, genInputs []generator.GeneratorInput, opts BuildOptions) error {
+	gens, err := newGenerators(genInputs)
+	if err != nil {
+		return err
+	}
+
+	all, err := artifacts(gens, genInputs, generator.Options{
+		CacheDir:    opts.CacheDir,
+		CachePolicy: opts.CachePolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	lockFile := lock.New()
+	for _, artifact := range all {
+		if !artifact.Cached {
+			fmt.Fprintf(w, "fetching %s: %s\n", artifact.label, artifact.Name) //nolint:errcheck
+		}
+
+		digest, err := artifact.Fetch()
+		if err != nil {
+			return fmt.Errorf("%s: when fetching %s: %w", artifact.label, artifact.Name, err)
+		}
+		lockFile.Artifacts[artifact.Name] = digest
+	}
+
+	return lockFile.Write(opts.LockFile)
+}
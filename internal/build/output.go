@@ -0,0 +1,109 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/amir-ahmad/kogen/internal/generator"
+)
+
+// OutputFormat is the format objects are written in.
+type OutputFormat string
+
+const (
+	// OutputFormatYAML writes a stream of yaml documents separated by ---.
+	OutputFormatYAML OutputFormat = "yaml"
+	// OutputFormatJSON writes newline-delimited json, one object per line.
+	OutputFormatJSON OutputFormat = "json"
+	// OutputFormatList writes a single v1/List json document containing every object.
+	OutputFormatList OutputFormat = "list"
+)
+
+// list is a kubernetes v1/List.
+type list struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Items      []json.RawMessage `json:"items"`
+}
+
+// objectWriter writes objects to a stream in an output format.
+type objectWriter struct {
+	w      io.Writer
+	format OutputFormat
+
+	// count is the number of objects written so far.
+	count int
+	// items holds the objects of a List until it is closed.
+	items []json.RawMessage
+}
+
+func newObjectWriter(w io.Writer, format OutputFormat) (*objectWriter, error) {
+	switch format {
+	case "":
+		format = OutputFormatYAML
+	case OutputFormatYAML, OutputFormatJSON, OutputFormatList:
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+
+	return &objectWriter{w: w, format: format}, nil
+}
+
+// Write writes a single object.
+func (ow *objectWriter) Write(object generator.Object) error {
+	defer func() { ow.count++ }()
+
+	switch ow.format {
+	case OutputFormatJSON:
+		jsonBytes, err := object.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		// Objects may be encoded with trailing whitespace, which would break the one line per
+		// object format.
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, jsonBytes); err != nil {
+			return fmt.Errorf("failed to compact object json: %w", err)
+		}
+		buf.WriteString("\n")
+
+		_, err = ow.w.Write(buf.Bytes())
+		return err
+	case OutputFormatList:
+		jsonBytes, err := object.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		ow.items = append(ow.items, jsonBytes)
+		return nil
+	default:
+		// The separator needs to be printed after every object but the last.
+		if ow.count > 0 {
+			fmt.Fprintf(ow.w, "---\n") //nolint:errcheck
+		}
+		return object.Output(ow.w)
+	}
+}
+
+// Close writes anything that can only be written once all objects are known.
+func (ow *objectWriter) Close() error {
+	if ow.format != OutputFormatList {
+		return nil
+	}
+
+	l := list{APIVersion: "v1", Kind: "List", Items: ow.items}
+	if l.Items == nil {
+		l.Items = []json.RawMessage{}
+	}
+
+	jsonBytes, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode list to json: %w", err)
+	}
+
+	_, err = fmt.Fprintf(ow.w, "%s\n", jsonBytes)
+	return err
+}
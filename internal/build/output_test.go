@@ -0,0 +1,120 @@
+package build
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObjectWriter(t *testing.T) {
+	objects := []*store.Object{
+		{
+			Unstructured: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]interface{}{
+						"name": "a",
+					},
+				},
+			},
+		},
+		{
+			Unstructured: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata": map[string]interface{}{
+						"name": "b",
+					},
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		format   OutputFormat
+		objects  []*store.Object
+		expected string
+	}{
+		"default is yaml": {
+			format:  "",
+			objects: objects,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: b
+`,
+		},
+		"json": {
+			format:  OutputFormatJSON,
+			objects: objects,
+			expected: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"}}
+{"apiVersion":"v1","kind":"Secret","metadata":{"name":"b"}}
+`,
+		},
+		"list": {
+			format:  OutputFormatList,
+			objects: objects,
+			expected: `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "apiVersion": "v1",
+      "kind": "ConfigMap",
+      "metadata": {
+        "name": "a"
+      }
+    },
+    {
+      "apiVersion": "v1",
+      "kind": "Secret",
+      "metadata": {
+        "name": "b"
+      }
+    }
+  ]
+}
+`,
+		},
+		"empty list": {
+			format: OutputFormatList,
+			expected: `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": []
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ow, err := newObjectWriter(&buf, tc.format)
+			require.NoError(t, err)
+
+			for _, object := range tc.objects {
+				require.NoError(t, ow.Write(object))
+			}
+			require.NoError(t, ow.Close())
+
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestObjectWriter_UnknownFormat(t *testing.T) {
+	_, err := newObjectWriter(&bytes.Buffer{}, "toml")
+	require.ErrorContains(t, err, `unknown output format "toml"`)
+}
@@ -0,0 +1,220 @@
+package build
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/internal/generator"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DefaultOutputLayout is the default layout of files written to an output directory.
+//
+// The placeholders <generator>, <group>, <version>, <kind>, <namespace> and <name> are
+// replaced with the generator label and the object's identity. Objects without a group are
+// written with the group "core" and cluster scoped objects with the namespace "_cluster".
+// Objects that resolve to the same path are written to the same file in build order, so a
+// layout such as "<generator>.yaml" writes one file per generator. Files are written in the
+// output format of the build, so the extension should be changed to match when using json.
+const DefaultOutputLayout = "<generator>/<namespace>/<kind>-<name>.yaml"
+
+// outputManifest is the file in an output directory listing the files written by the last
+// build, one slash separated path per line. Only files listed in it are ever pruned.
+const outputManifest = ".kogen-output"
+
+// writeOutputDir writes objects to files in opts.OutputDir according to opts.OutputLayout.
+// Files written by the previous build that are no longer generated are removed, along with any
+// directories that become empty, so kogen's files in the directory are only ever the current
+// build output. Other files are left alone, and a non-empty directory without an output
+// manifest is refused so that kogen never takes over a directory it didn't create.
+func writeOutputDir(objects []builtObject, opts BuildOptions) error {
+	layout := opts.OutputLayout
+	if layout == "" {
+		layout = DefaultOutputLayout
+	}
+
+	previous, err := readOutputManifest(opts.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]*bytes.Buffer{}
+	writers := map[string]*objectWriter{}
+	for _, object := range objects {
+		path, err := outputPath(layout, object.label, object)
+		if err != nil {
+			return err
+		}
+
+		ow, ok := writers[path]
+		if !ok {
+			files[path] = &bytes.Buffer{}
+			ow, err = newObjectWriter(files[path], opts.OutputFormat)
+			if err != nil {
+				return err
+			}
+			writers[path] = ow
+		}
+
+		if err := ow.Write(object); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		if err := writers[path].Close(); err != nil {
+			return err
+		}
+		if err := writeFileIfChanged(filepath.Join(opts.OutputDir, path), files[path].Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := pruneOutputDir(opts.OutputDir, previous, files); err != nil {
+		return err
+	}
+
+	return writeOutputManifest(opts.OutputDir, slices.Sorted(maps.Keys(files)))
+}
+
+// outputPath returns the path of the file an object is written to, relative to the output
+// directory.
+func outputPath(layout string, label string, object generator.Object) (string, error) {
+	gv, err := schema.ParseGroupVersion(object.GetAPIVersion())
+	if err != nil {
+		return "", fmt.Errorf("when parsing apiVersion of %s %s: %w", object.GetKind(), object.GetName(), err)
+	}
+
+	group := gv.Group
+	if group == "" {
+		group = "core"
+	}
+
+	namespace := object.GetNamespace()
+	if namespace == "" {
+		namespace = "_cluster"
+	}
+
+	// Values are sanitised so that they can't introduce extra path segments.
+	sanitise := strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace
+	path := strings.NewReplacer(
+		"<generator>", sanitise(label),
+		"<group>", sanitise(group),
+		"<version>", sanitise(gv.Version),
+		"<kind>", sanitise(object.GetKind()),
+		"<namespace>", sanitise(namespace),
+		"<name>", sanitise(object.GetName()),
+	).Replace(layout)
+
+	path = filepath.FromSlash(path)
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("output path %q is not within the output directory", path)
+	}
+
+	return path, nil
+}
+
+// writeFileIfChanged writes data to path, leaving the file untouched when it already has the
+// same content.
+func writeFileIfChanged(path string, data []byte) error {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("when creating output directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("when writing output file: %w", err)
+	}
+
+	return nil
+}
+
+// readOutputManifest returns the files written to dir by the previous build. It fails when dir
+// isn't empty and has no output manifest.
+func readOutputManifest(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, outputManifest))
+	if errors.Is(err, fs.ErrNotExist) {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("when reading output directory: %w", err)
+		}
+		if len(entries) > 0 {
+			return nil, fmt.Errorf(
+				"output directory %s isn't empty and has no %s file, so it wasn't written by kogen",
+				dir,
+				outputManifest,
+			)
+		}
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("when reading output manifest: %w", err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		path := filepath.FromSlash(line)
+		// Entries that could point outside the output directory are ignored.
+		if line != "" && filepath.IsLocal(path) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// writeOutputManifest records the files written to dir.
+func writeOutputManifest(dir string, files []string) error {
+	var buf bytes.Buffer
+	for _, file := range files {
+		buf.WriteString(filepath.ToSlash(file) + "\n")
+	}
+
+	if err := writeFileIfChanged(filepath.Join(dir, outputManifest), buf.Bytes()); err != nil {
+		return fmt.Errorf("when writing output manifest: %w", err)
+	}
+	return nil
+}
+
+// pruneOutputDir removes the previous files in dir that aren't in files, then removes the
+// directories that become empty.
+func pruneOutputDir(dir string, previous []string, files map[string]*bytes.Buffer) error {
+	dirs := map[string]bool{}
+	for _, path := range previous {
+		if _, ok := files[path]; ok {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("when removing stale output file: %w", err)
+		}
+		for d := filepath.Dir(path); d != "."; d = filepath.Dir(d) {
+			dirs[d] = true
+		}
+	}
+
+	// Remove the deepest directories first so that parents can become empty.
+	for _, d := range slices.Backward(slices.Sorted(maps.Keys(dirs))) {
+		entries, err := os.ReadDir(filepath.Join(dir, d))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("when pruning output directory: %w", err)
+		}
+		if len(entries) == 0 {
+			if err := os.Remove(filepath.Join(dir, d)); err != nil {
+				return fmt.Errorf("when pruning output directory: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestOutputPath(t *testing.T) {
+	tests := map[string]struct {
+		layout      string
+		label       string
+		object      map[string]interface{}
+		expected    string
+		expectError string
+	}{
+		"default layout": {
+			layout: DefaultOutputLayout,
+			label:  "app",
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":      "web",
+					"namespace": "prod",
+				},
+			},
+			expected: "app/prod/Deployment-web.yaml",
+		},
+		"cluster scoped core object": {
+			layout: "<group>/<version>/<namespace>/<kind>-<name>.yaml",
+			label:  "app",
+			object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Namespace",
+				"metadata": map[string]interface{}{
+					"name": "prod",
+				},
+			},
+			expected: "core/v1/_cluster/Namespace-prod.yaml",
+		},
+		"per generator": {
+			layout: "<generator>.yaml",
+			label:  "cert-manager",
+			object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"name": "config",
+				},
+			},
+			expected: "cert-manager.yaml",
+		},
+		"values can't add path segments": {
+			layout: DefaultOutputLayout,
+			label:  "../app",
+			object: map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "ClusterRole",
+				"metadata": map[string]interface{}{
+					"name": "system:foo/bar",
+				},
+			},
+			expected: ".._app/_cluster/ClusterRole-system:foo_bar.yaml",
+		},
+		"layout outside output dir": {
+			layout: "../<name>.yaml",
+			label:  "app",
+			object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"name": "config",
+				},
+			},
+			expectError: "is not within the output directory",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			object := &store.Object{Unstructured: &unstructured.Unstructured{Object: tc.object}}
+			path, err := outputPath(tc.layout, tc.label, object)
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, path)
+		})
+	}
+}
+
+func TestWriteOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	opts := BuildOptions{OutputDir: dir}
+
+	require.NoError(t, writeOutputDir([]builtObject{
+		newConfigMap("app", "default", "one"),
+		newConfigMap("app", "default", "two"),
+	}, opts))
+	assert.FileExists(t, filepath.Join(dir, "app/default/ConfigMap-two.yaml"))
+
+	manifest, err := os.ReadFile(filepath.Join(dir, outputManifest))
+	require.NoError(t, err)
+	assert.Equal(t, "app/default/ConfigMap-one.yaml\napp/default/ConfigMap-two.yaml\n", string(manifest))
+
+	// Files that kogen didn't write survive, even when they look like manifests.
+	unrelated := filepath.Join(dir, "app/default/ConfigMap-other.yaml")
+	require.NoError(t, os.WriteFile(unrelated, []byte("kind: ConfigMap\n"), 0o644))
+
+	require.NoError(t, writeOutputDir([]builtObject{newConfigMap("app", "default", "one")}, opts))
+	assert.FileExists(t, filepath.Join(dir, "app/default/ConfigMap-one.yaml"))
+	assert.NoFileExists(t, filepath.Join(dir, "app/default/ConfigMap-two.yaml"))
+	assert.FileExists(t, unrelated)
+
+	// A directory with files but no output manifest wasn't written by kogen.
+	other := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(other, "deployment.yaml"), nil, 0o644))
+	err = writeOutputDir([]builtObject{newConfigMap("app", "default", "one")}, BuildOptions{OutputDir: other})
+	require.ErrorContains(t, err, "has no .kogen-output file")
+	assert.FileExists(t, filepath.Join(other, "deployment.yaml"))
+}
@@ -0,0 +1,141 @@
+package build
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/internal/generator"
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/amir-ahmad/kogen/internal/validate"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// manifestExtensions are the file extensions read from CRD directories.
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// validateObjects validates objects against Kubernetes schemas, and custom resources against
+// the CRDs in crdSources or crdPaths. All errors are returned together.
+func validateObjects(objects, crdSources []builtObject, crdPaths []string) error {
+	validator := validate.NewValidator()
+
+	for _, object := range crdSources {
+		if object.GetKind() != validate.CRDGroupKind.Kind {
+			continue
+		}
+		u, err := toUnstructured(object)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", object.label, generator.ObjectKey(object), err)
+		}
+		if u.GroupVersionKind().GroupKind() == validate.CRDGroupKind {
+			if err := validator.AddCRD(u); err != nil {
+				return fmt.Errorf("%s: %w", object.label, err)
+			}
+		}
+	}
+
+	unstructuredObjects := make([]*unstructured.Unstructured, len(objects))
+	for i, object := range objects {
+		u, err := toUnstructured(object)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", object.label, generator.ObjectKey(object), err)
+		}
+		unstructuredObjects[i] = u
+	}
+
+	for _, crdPath := range crdPaths {
+		if err := addCRDs(validator, crdPath); err != nil {
+			return err
+		}
+	}
+
+	var errs []string
+	for i, object := range objects {
+		for _, err := range validator.Validate(unstructuredObjects[i], object.kubeVersion) {
+			errs = append(errs, fmt.Sprintf(
+				"%s: %s: %v",
+				object.label,
+				generator.ObjectKey(object),
+				err,
+			))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf(
+			"validation failed with %d errors:\n  %s",
+			len(errs),
+			strings.Join(errs, "\n  "),
+		)
+	}
+
+	return nil
+}
+
+// toUnstructured converts an object to unstructured through its json encoding.
+func toUnstructured(object generator.Object) (*unstructured.Unstructured, error) {
+	jsonBytes, err := object.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(jsonBytes); err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+
+	return u, nil
+}
+
+// addCRDs adds every CustomResourceDefinition in a file, or in the yaml and json files of a
+// directory, to the validator.
+func addCRDs(validator *validate.Validator, crdPath string) error {
+	info, err := os.Stat(crdPath)
+	if err != nil {
+		return fmt.Errorf("when accessing CRD path %s: %w", crdPath, err)
+	}
+
+	files := []string{crdPath}
+	if info.IsDir() {
+		files = nil
+		err := filepath.WalkDir(crdPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if !d.IsDir() && slices.Contains(manifestExtensions, ext) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("when reading CRD directory %s: %w", crdPath, err)
+		}
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("when reading CRD file %s: %w", file, err)
+		}
+
+		st := store.NewObjectStore()
+		if err := st.AddYaml(data); err != nil {
+			return fmt.Errorf("when parsing CRD file %s: %w", file, err)
+		}
+
+		for _, object := range *st {
+			if object.GroupVersionKind().GroupKind() != validate.CRDGroupKind {
+				continue
+			}
+			if err := validator.AddCRD(object.Unstructured); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package cache stores downloaded artifacts in the cache directory.
+package cache
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to a temporary file and renames it to path, so that concurrent
+// readers never see a partially written file. The directory of path is created if needed.
+func WriteFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name()) //nolint:errcheck
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// kinds are the directories of the cache that hold entries, one for each kind of artifact.
+var kinds = []string{"git", "git/refs", "helm", "kustomize", "resources"}
+
+// Entry is an artifact in the cache, such as a downloaded file or an extracted chart.
+type Entry struct {
+	// Kind is the directory of the cache the entry is in, such as helm or resources.
+	Kind string
+	// Name of the entry within the directory of its kind.
+	Name string
+	// Source is the url the entry was downloaded from, when it's known.
+	Source string
+	// Size is the number of bytes the entry takes up, including its metadata.
+	Size int64
+	// LastUsed is when the entry was last used by a build.
+	LastUsed time.Time
+}
+
+// Path returns the path of the entry in cacheDir.
+func (e Entry) Path(cacheDir string) string {
+	return filepath.Join(cacheDir, filepath.FromSlash(e.Kind), e.Name)
+}
+
+// Touch records that the entry at path was used, so that it isn't pruned. Errors are ignored,
+// as a cache that can't be written to can still be read from.
+func Touch(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
+
+// List returns every entry in cacheDir, sorted by kind and name.
+func List(cacheDir string) ([]Entry, error) {
+	return list(cacheDir, false)
+}
+
+// list returns the entries in cacheDir. Hidden entries are the temporary files and directories
+// of downloads, which are only included when hidden is set.
+func list(cacheDir string, hidden bool) ([]Entry, error) {
+	var entries []Entry
+	for _, kind := range kinds {
+		dir := filepath.Join(cacheDir, filepath.FromSlash(kind))
+		dirEntries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache directory %s: %w", dir, err)
+		}
+
+		for _, dirEntry := range dirEntries {
+			name := dirEntry.Name()
+			switch {
+			case strings.HasSuffix(name, metaSuffix):
+				continue
+			case strings.HasPrefix(name, ".") && !hidden:
+				continue
+			case slices.Contains(kinds, kind+"/"+name):
+				continue
+			}
+
+			entry, err := newEntry(cacheDir, kind, name)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// newEntry returns the entry called name in the directory of kind.
+func newEntry(cacheDir, kind, name string) (Entry, error) {
+	entry := Entry{Kind: kind, Name: name}
+	path := entry.Path(cacheDir)
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read cache entry %s: %w", path, err)
+	}
+	entry.LastUsed = info.ModTime()
+
+	for _, p := range []string{path, path + metaSuffix} {
+		err := filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if info, err := d.Info(); err == nil && !d.IsDir() {
+				entry.Size += info.Size()
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return Entry{}, fmt.Errorf("failed to read cache entry %s: %w", path, err)
+		}
+	}
+
+	if data, err := os.ReadFile(path + metaSuffix); err == nil {
+		var meta metadata
+		if err := json.Unmarshal(data, &meta); err == nil {
+			entry.Source = meta.URL
+		}
+	}
+
+	return entry, nil
+}
+
+// remove removes the entry and its metadata from cacheDir.
+func (e Entry) remove(cacheDir string) error {
+	path := e.Path(cacheDir)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove cache entry %s: %w", path, err)
+	}
+	if err := os.Remove(path + metaSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove cache entry %s: %w", path, err)
+	}
+	return nil
+}
+
+// Prune removes the entries in cacheDir that haven't been used for longer than maxAge, along
+// with temporary files left behind by interrupted downloads, and returns the entries removed.
+func Prune(cacheDir string, maxAge time.Duration) ([]Entry, error) {
+	entries, err := list(cacheDir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var removed []Entry
+	for _, entry := range entries {
+		if !entry.LastUsed.Before(cutoff) {
+			continue
+		}
+		if err := entry.remove(cacheDir); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+	return removed, nil
+}
+
+// Clear removes every entry in cacheDir. Files in cacheDir that kogen didn't create are left
+// alone.
+func Clear(cacheDir string) error {
+	for _, kind := range kinds {
+		dir := filepath.Join(cacheDir, filepath.FromSlash(kind))
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove cache directory %s: %w", dir, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPruneClear(t *testing.T) {
+	cacheDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for path, data := range map[string]string{
+		"resources/0123.yaml":               "resource",
+		"resources/0123.yaml.meta":          `{"url":"https://example.com/a.yaml"}`,
+		"helm/repo-index.yaml":              "index",
+		"helm/repo-nginx-1.0.0/digest":      "sha256:aa",
+		"helm/repo-nginx-1.0.0/nginx/a.txt": "chart",
+		"helm/.download-123/partial":        "partial",
+		"git/abcdef/file.yaml":              "file",
+		"git/refs/4567":                     "abcdef",
+		"other/file":                        "not kogen's",
+	} {
+		path = filepath.Join(cacheDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+	for _, path := range []string{"resources/0123.yaml", "helm/repo-nginx-1.0.0", "helm/.download-123"} {
+		require.NoError(t, os.Chtimes(filepath.Join(cacheDir, path), old, old))
+	}
+
+	entries, err := List(cacheDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Kind+"/"+entry.Name)
+	}
+	assert.Equal(t, []string{
+		"git/abcdef",
+		"git/refs/4567",
+		"helm/repo-index.yaml",
+		"helm/repo-nginx-1.0.0",
+		"resources/0123.yaml",
+	}, names)
+	assert.Equal(t, Entry{
+		Kind:     "resources",
+		Name:     "0123.yaml",
+		Source:   "https://example.com/a.yaml",
+		Size:     int64(len("resource") + len(`{"url":"https://example.com/a.yaml"}`)),
+		LastUsed: entries[4].LastUsed,
+	}, entries[4])
+	assert.Equal(t, int64(len("sha256:aa")+len("chart")), entries[3].Size)
+
+	removed, err := Prune(cacheDir, 24*time.Hour)
+	require.NoError(t, err)
+	names = nil
+	for _, entry := range removed {
+		names = append(names, entry.Kind+"/"+entry.Name)
+	}
+	assert.Equal(t, []string{"helm/.download-123", "helm/repo-nginx-1.0.0", "resources/0123.yaml"}, names)
+	assert.NoFileExists(t, filepath.Join(cacheDir, "resources/0123.yaml.meta"))
+	assert.FileExists(t, filepath.Join(cacheDir, "helm/repo-index.yaml"))
+
+	require.NoError(t, Clear(cacheDir))
+	entries, err = List(cacheDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.FileExists(t, filepath.Join(cacheDir, "other/file"))
+}
@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Policy is when artifacts that can change at their source, such as files downloaded over http
+// the output of remote kustomizations and the commits git refs resolve to, are revalidated. Other
+// artifacts, such as chart versions and full git commits, are immutable and never revalidated.
+type Policy struct {
+	// TTL is how long a downloaded file is used before it's revalidated. Files are used
+	// forever when zero.
+	TTL time.Duration
+
+	// Refresh revalidates every downloaded file, regardless of TTL.
+	Refresh bool
+}
+
+// metaSuffix is appended to the path of a downloaded file to get the path of its metadata.
+const metaSuffix = ".meta"
+
+// metadata is recorded for a downloaded file so that it can be revalidated.
+type metadata struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
+
+// readMetadata returns the metadata of the file at path. Files downloaded by older versions of
+// kogen have none, so they're treated as checked when they were written.
+func readMetadata(path string) (metadata, error) {
+	data, err := os.ReadFile(path + metaSuffix)
+	if err == nil {
+		var meta metadata
+		if err := json.Unmarshal(data, &meta); err == nil {
+			return meta, nil
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return metadata{}, err
+	}
+	return metadata{CheckedAt: info.ModTime()}, nil
+}
+
+func writeMetadata(path string, meta metadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return WriteFile(path+metaSuffix, data)
+}
+
+// Request is a file to download over http.
+type Request struct {
+	URL string
+
+	// Headers are sent with every attempt to download the file.
+	Headers map[string]string
+
+	// SHA256 is the expected digest of the file in hex. Files that don't match it are never
+	// cached or returned.
+	SHA256 string
+
+	// Timeout is how long each attempt can take. Defaults to DefaultTimeout when zero.
+	Timeout time.Duration
+
+	// Retries is how many times the download is retried after a network error or a 429 or 5xx
+	// response.
+	Retries int
+}
+
+const (
+	// DefaultTimeout is how long each attempt to download a file can take by default.
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultRetries is how many times downloads are retried by default.
+	DefaultRetries = 3
+)
+
+// retryBackoff is how long to wait before the first retry, which doubles before each one after.
+var retryBackoff = time.Second
+
+// verify returns an error if data doesn't match the expected digest of the request.
+func (r Request) verify(data []byte) error {
+	if r.SHA256 == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256(data)
+	if digest := hex.EncodeToString(sum[:]); !strings.EqualFold(digest, r.SHA256) {
+		return fmt.Errorf("sha256 of %s is %s, but %s is expected", r.URL, digest, r.SHA256)
+	}
+	return nil
+}
+
+// readCached returns the file cached at path, if it matches the expected digest.
+func (r Request) readCached(path string) ([]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil || r.verify(data) != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// IsCached returns whether the file of req has been downloaded to path, matches its expected
+// digest and doesn't need to be revalidated yet.
+func (p Policy) IsCached(req Request, path string) bool {
+	meta, err := readMetadata(path)
+	if err != nil || p.isStale(meta) {
+		return false
+	}
+	_, ok := req.readCached(path)
+	return ok
+}
+
+// IsFresh returns whether the file at path exists and doesn't need to be revalidated yet. It's
+// used for files that are revalidated by recreating them, which are written with WriteChecked.
+func (p Policy) IsFresh(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+
+	data, err := os.ReadFile(path + metaSuffix)
+	var meta metadata
+	if err != nil || json.Unmarshal(data, &meta) != nil {
+		// Using a file changes its modification time, so without metadata it's unknown when it
+		// was checked. It's only fresh when files are never revalidated.
+		return !p.Refresh && p.TTL == 0
+	}
+	return !p.isStale(meta)
+}
+
+// WriteChecked writes data, which was just created from source, to path and records when it
+// was checked for IsFresh.
+func WriteChecked(path, source string, data []byte) error {
+	if err := WriteFile(path, data); err != nil {
+		return err
+	}
+	return writeMetadata(path, metadata{URL: source, CheckedAt: time.Now()})
+}
+
+func (p Policy) isStale(meta metadata) bool {
+	if p.Refresh {
+		return true
+	}
+	return p.TTL > 0 && time.Since(meta.CheckedAt) > p.TTL
+}
+
+// Download returns the contents of the file of req, which are cached at path. The cached copy
+// is used until the policy requires it to be revalidated, in which case it's only downloaded
+// again if the server doesn't report it as unmodified through its ETag or Last-Modified
+// headers. Cached copies that don't match the expected digest are downloaded again.
+func Download(req Request, path string, policy Policy) ([]byte, error) {
+	meta, err := readMetadata(path)
+	cached := err == nil
+
+	var data []byte
+	if cached {
+		data, cached = req.readCached(path)
+	}
+
+	if cached && !policy.isStale(meta) {
+		// Files without metadata are given some, as using them changes their modification time.
+		if meta.URL == "" {
+			meta.URL = req.URL
+			if err := writeMetadata(path, meta); err != nil {
+				return nil, fmt.Errorf("when recording metadata of %s: %w", req.URL, err)
+			}
+		}
+		Touch(path)
+		return data, nil
+	}
+
+	var conditional metadata
+	if cached {
+		conditional = meta
+	}
+	status, header, body, err := req.fetch(conditional)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && status == http.StatusNotModified {
+		meta.URL = req.URL
+		meta.CheckedAt = time.Now()
+		if err := writeMetadata(path, meta); err != nil {
+			return nil, fmt.Errorf("when recording revalidation of %s: %w", req.URL, err)
+		}
+		Touch(path)
+		return data, nil
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", req.URL, status)
+	}
+
+	if err := req.verify(body); err != nil {
+		return nil, err
+	}
+
+	if err := WriteFile(path, body); err != nil {
+		return nil, fmt.Errorf("when caching %s: %w", req.URL, err)
+	}
+	meta = metadata{
+		URL:          req.URL,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		CheckedAt:    time.Now(),
+	}
+	if err := writeMetadata(path, meta); err != nil {
+		return nil, fmt.Errorf("when caching %s: %w", req.URL, err)
+	}
+
+	return body, nil
+}
+
+// Get downloads the file of req without caching it, retrying like Download does.
+func (r Request) Get() ([]byte, error) {
+	status, _, body, err := r.fetch(metadata{})
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", r.URL, status)
+	}
+
+	if err := r.verify(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// fetch requests the file, conditionally on it differing from the cached copy described by
+// conditional, and retries with backoff after network errors and 429 or 5xx responses. It
+// returns the response of the last attempt.
+func (r Request) fetch(conditional metadata) (int, http.Header, []byte, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		status, header, body, err := r.attempt(client, conditional)
+		retry := err != nil || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+		if !retry || attempt >= r.Retries {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w (after %d retries)", err, attempt)
+			}
+			return status, header, body, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// attempt makes a single request for the file.
+func (r Request) attempt(client *http.Client, conditional metadata) (int, http.Header, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, r.URL, nil)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("when creating request for %s: %w", r.URL, err)
+	}
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+	if conditional.ETag != "" {
+		req.Header.Set("If-None-Match", conditional.ETag)
+	}
+	if conditional.LastModified != "" {
+		req.Header.Set("If-Modified-Since", conditional.LastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("when fetching %s: %w", r.URL, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("when reading %s: %w", r.URL, err)
+	}
+	return resp.StatusCode, resp.Header, body, nil
+}
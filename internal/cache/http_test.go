@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownload(t *testing.T) {
+	body := "v1"
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "resources", "file.yaml")
+	req := Request{URL: server.URL}
+	policy := Policy{TTL: time.Hour}
+
+	data, err := Download(req, path, policy)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+	assert.True(t, policy.IsCached(req, path))
+
+	t.Run("fresh files are used without a request", func(t *testing.T) {
+		data, err := Download(req, path, policy)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", string(data))
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("unmodified files are revalidated with their etag", func(t *testing.T) {
+		refresh := Policy{Refresh: true}
+		assert.False(t, refresh.IsCached(req, path))
+
+		data, err := Download(req, path, refresh)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", string(data))
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, 1, notModified)
+	})
+
+	t.Run("stale files are downloaded again when modified", func(t *testing.T) {
+		body = "v2"
+		meta, err := readMetadata(path)
+		require.NoError(t, err)
+		meta.CheckedAt = time.Now().Add(-2 * time.Hour)
+		require.NoError(t, writeMetadata(path, meta))
+		assert.False(t, policy.IsCached(req, path))
+
+		data, err := Download(req, path, policy)
+		require.NoError(t, err)
+		assert.Equal(t, "v2", string(data))
+		assert.Equal(t, 3, requests)
+		assert.True(t, policy.IsCached(req, path))
+	})
+
+	t.Run("files are used forever without a ttl", func(t *testing.T) {
+		meta, err := readMetadata(path)
+		require.NoError(t, err)
+		meta.CheckedAt = time.Now().Add(-24 * 365 * time.Hour)
+		require.NoError(t, writeMetadata(path, meta))
+		assert.True(t, Policy{}.IsCached(req, path))
+	})
+}
+
+func TestDownload_WithoutMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	}))
+	defer server.Close()
+
+	// Files cached by older versions of kogen are treated as checked when they were written.
+	path := filepath.Join(t.TempDir(), "file.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o644))
+
+	data, err := Download(Request{URL: server.URL}, path, Policy{TTL: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+
+	meta, err := readMetadata(path)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL, meta.URL)
+}
+
+func TestDownload_Error(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := Download(Request{URL: server.URL}, filepath.Join(t.TempDir(), "file.yaml"), Policy{})
+	require.ErrorContains(t, err, "status 404")
+}
+
+func TestDownload_Checksum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.Write([]byte("data")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file.yaml")
+	headers := map[string]string{"Authorization": "Bearer token"}
+
+	// sha256 of "other".
+	req := Request{URL: server.URL, Headers: headers, SHA256: "d9298a10d1b0735837dc4bd85dac641b0f3cef27a47e5d53a54f2f3f5b2fcffa"}
+	_, err := Download(req, path, Policy{})
+	require.ErrorContains(t, err, "sha256 of "+server.URL+" is 3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7, but d9298a10")
+	assert.NoFileExists(t, path)
+
+	req.SHA256 = "3A6EB0790F39AC87C94F3856B2DD2C5D110E6811602261A9A923D3BB23ADC8B7"
+	data, err := Download(req, path, Policy{})
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.True(t, Policy{}.IsCached(req, path))
+
+	// Cached files that don't match the checksum aren't used.
+	require.NoError(t, os.WriteFile(path, []byte("tampered"), 0o644))
+	assert.False(t, Policy{}.IsCached(req, path))
+	data, err = Download(req, path, Policy{})
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestDownload_Retries(t *testing.T) {
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = time.Second })
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 3:
+			// Slower than the timeout.
+			time.Sleep(100 * time.Millisecond)
+		default:
+			w.Write([]byte("data")) //nolint:errcheck
+		}
+	}))
+	defer server.Close()
+
+	req := Request{URL: server.URL, Timeout: 50 * time.Millisecond, Retries: 2}
+	_, err := Download(req, filepath.Join(t.TempDir(), "file.yaml"), Policy{})
+	require.ErrorContains(t, err, "(after 2 retries)")
+	assert.Equal(t, int32(3), requests.Load())
+
+	requests.Store(0)
+	req.Retries = 3
+	data, err := Download(req, filepath.Join(t.TempDir(), "file.yaml"), Policy{})
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.Equal(t, int32(4), requests.Load())
+
+	// Client errors aren't retried.
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer notFound.Close()
+
+	requests.Store(0)
+	_, err = Download(Request{URL: notFound.URL, Retries: 3}, filepath.Join(t.TempDir(), "file.yaml"), Policy{})
+	require.ErrorContains(t, err, "status 404")
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRequest_Get(t *testing.T) {
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = time.Second })
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("data")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	_, err := Request{URL: server.URL}.Get()
+	require.ErrorContains(t, err, "status 502")
+
+	data, err := Request{URL: server.URL, Retries: 1}.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.Equal(t, int32(3), requests.Load())
+
+	_, err = Request{URL: server.URL, SHA256: strings.Repeat("0", 64)}.Get()
+	require.ErrorContains(t, err, "sha256 of "+server.URL)
+}
@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/internal/build"
+	"github.com/amir-ahmad/kogen/internal/generator"
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Source is a rendered stream of yaml objects to compare.
+type Source struct {
+	// Name describes where the objects came from, e.g. a git revision or a file name.
+	Name string
+
+	// Data is the multi-document yaml stream.
+	Data []byte
+}
+
+// Result holds the keys of objects that differ between two sources.
+type Result struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// HasChanges returns true when any object was added, removed or changed.
+func (r Result) HasChanges() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0 || len(r.Changed) > 0
+}
+
+// Objects compares the objects in from and to, keyed by their group/version, kind, namespace
+// and name. A unified diff of every added, removed and changed object is written to w.
+// Objects that appear more than once in a source are handled according to duplicates, as in a
+// build.
+func Objects(w io.Writer, from, to Source, duplicates build.DuplicatePolicy) (Result, error) {
+	fromObjects, err := renderObjects(from, duplicates)
+	if err != nil {
+		return Result{}, err
+	}
+
+	toObjects, err := renderObjects(to, duplicates)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	for _, key := range slices.Sorted(maps.Keys(toObjects)) {
+		fromYaml, ok := fromObjects[key]
+		if !ok {
+			result.Added = append(result.Added, key)
+		} else if fromYaml != toObjects[key] {
+			result.Changed = append(result.Changed, key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(fromObjects)) {
+		if _, ok := toObjects[key]; !ok {
+			result.Removed = append(result.Removed, key)
+		}
+	}
+
+	sections := []struct {
+		title string
+		keys  []string
+	}{
+		{"Added", result.Added},
+		{"Removed", result.Removed},
+		{"Changed", result.Changed},
+	}
+
+	printSeparator := false
+	for _, section := range sections {
+		if len(section.keys) == 0 {
+			continue
+		}
+
+		if printSeparator {
+			fmt.Fprintf(w, "\n") //nolint:errcheck
+		} else {
+			printSeparator = true
+		}
+		fmt.Fprintf(w, "# %s (%d)\n", section.title, len(section.keys)) //nolint:errcheck
+
+		for _, key := range section.keys {
+			fromName, toName := from.Name+": "+key, to.Name+": "+key
+			if _, ok := fromObjects[key]; !ok {
+				fromName = "/dev/null"
+			}
+			if _, ok := toObjects[key]; !ok {
+				toName = "/dev/null"
+			}
+
+			if err := difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+				A:        splitLines(fromObjects[key]),
+				B:        splitLines(toObjects[key]),
+				FromFile: fromName,
+				ToFile:   toName,
+				Context:  3,
+			}); err != nil {
+				return Result{}, fmt.Errorf("when writing diff for %s: %w", key, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// renderObjects parses a source and returns each object's normalised yaml by its key. When
+// duplicates is DuplicatePolicyWarn, later copies of an object are keyed by their position, such
+// as "v1|ConfigMap||a (2)", otherwise they fail.
+func renderObjects(source Source, duplicates build.DuplicatePolicy) (map[string]string, error) {
+	manifests, err := store.DecodeYaml(source.Data)
+	if err != nil {
+		return nil, fmt.Errorf("when parsing objects from %s: %w", source.Name, err)
+	}
+
+	objects := make(map[string]string, len(manifests))
+	counts := map[string]int{}
+	for _, manifest := range manifests {
+		object := &store.Object{Unstructured: manifest}
+		key := generator.ObjectKey(object)
+		counts[key]++
+		if counts[key] > 1 {
+			if duplicates != build.DuplicatePolicyWarn {
+				return nil, fmt.Errorf("when parsing objects from %s: object %s already exists", source.Name, key)
+			}
+			key = fmt.Sprintf("%s (%d)", key, counts[key])
+		}
+
+		var buf bytes.Buffer
+		if err := object.Output(&buf); err != nil {
+			return nil, err
+		}
+		objects[key] = buf.String()
+	}
+
+	return objects, nil
+}
+
+// splitLines splits s into lines, keeping the line endings.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
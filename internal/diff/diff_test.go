@@ -0,0 +1,176 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amir-ahmad/kogen/internal/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjects(t *testing.T) {
+	tests := map[string]struct {
+		from           string
+		to             string
+		expectedResult Result
+		expectedOutput string
+	}{
+		"identical": {
+			from: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+`,
+			to: `
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: a
+`,
+			expectedResult: Result{},
+			expectedOutput: "",
+		},
+		"added and removed": {
+			from: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`,
+			to: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+  namespace: default
+`,
+			expectedResult: Result{
+				Added:   []string{"v1|ConfigMap|default|new"},
+				Removed: []string{"v1|ConfigMap||old"},
+			},
+			expectedOutput: `# Added (1)
+--- /dev/null
++++ to: v1|ConfigMap|default|new
+@@ -0,0 +1,5 @@
++apiVersion: v1
++kind: ConfigMap
++metadata:
++  name: new
++  namespace: default
+
+# Removed (1)
+--- from: v1|ConfigMap||old
++++ /dev/null
+@@ -1,4 +0,0 @@
+-apiVersion: v1
+-kind: ConfigMap
+-metadata:
+-  name: old
+`,
+		},
+		"changed": {
+			from: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+`,
+			to: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: two
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+`,
+			expectedResult: Result{
+				Changed: []string{"v1|ConfigMap||a"},
+			},
+			expectedOutput: `# Changed (1)
+--- from: v1|ConfigMap||a
++++ to: v1|ConfigMap||a
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-  key: one
++  key: two
+ kind: ConfigMap
+ metadata:
+   name: a
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			result, err := Objects(
+				&buf,
+				Source{Name: "from", Data: []byte(tc.from)},
+				Source{Name: "to", Data: []byte(tc.to)},
+				build.DuplicatePolicyError,
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedResult, result)
+			assert.Equal(t, tc.expectedResult.HasChanges(), buf.Len() > 0)
+			assert.Equal(t, tc.expectedOutput, buf.String())
+		})
+	}
+}
+
+func TestObjects_InvalidYaml(t *testing.T) {
+	_, err := Objects(
+		&bytes.Buffer{},
+		Source{Name: "saved.yaml", Data: []byte("- not\n- an object\n")},
+		Source{Name: "to"},
+		build.DuplicatePolicyError,
+	)
+	require.ErrorContains(t, err, "when parsing objects from saved.yaml")
+}
+
+func TestObjects_Duplicates(t *testing.T) {
+	from := Source{Name: "from", Data: []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: two
+`)}
+	to := Source{Name: "to", Data: []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: one
+`)}
+
+	_, err := Objects(&bytes.Buffer{}, from, to, build.DuplicatePolicyError)
+	require.ErrorContains(t, err, "when parsing objects from from: object v1|ConfigMap||a already exists")
+
+	result, err := Objects(&bytes.Buffer{}, from, to, build.DuplicatePolicyWarn)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Removed: []string{"v1|ConfigMap||a (2)"}}, result)
+}
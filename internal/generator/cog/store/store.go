@@ -52,6 +52,30 @@ func (s *ObjectStore) Add(obj *Object) error {
 
 // AddYaml adds yaml objects to the store.
 func (s *ObjectStore) AddYaml(yamlBytes []byte) error {
+	return s.AddYamlWithAnnotations(yamlBytes, nil)
+}
+
+// AddYamlWithAnnotations adds yaml objects to the store, setting annotations on each of them.
+func (s *ObjectStore) AddYamlWithAnnotations(yamlBytes []byte, annotations map[string]string) error {
+	manifests, err := DecodeYaml(yamlBytes)
+	if err != nil {
+		return err
+	}
+
+	for _, manifest := range manifests {
+		if len(annotations) > 0 {
+			SetAnnotations(manifest, annotations)
+		}
+		if err := s.Add(&Object{manifest}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeYaml decodes a stream of yaml documents into objects.
+func DecodeYaml(yamlBytes []byte) ([]*unstructured.Unstructured, error) {
+	var manifests []*unstructured.Unstructured
 	decoder := util_yaml.NewYAMLToJSONDecoder(bytes.NewReader(yamlBytes))
 	for {
 		var manifest *unstructured.Unstructured
@@ -59,17 +83,25 @@ func (s *ObjectStore) AddYaml(yamlBytes []byte) error {
 			if err == io.EOF {
 				break
 			}
-			return err
+			return nil, err
 		}
 		// Sometimes helm can output nil yaml objects which we need to skip.
 		if manifest == nil {
 			continue
 		}
-		if err := s.Add(&Object{manifest}); err != nil {
-			return err
-		}
+		manifests = append(manifests, manifest)
 	}
-	return nil
+	return manifests, nil
+}
+
+// SetAnnotations sets annotations on an object, keeping any others it already has.
+func SetAnnotations(obj *unstructured.Unstructured, annotations map[string]string) {
+	merged := obj.GetAnnotations()
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, annotations)
+	obj.SetAnnotations(merged)
 }
 
 // GetIterator returns an iterator for the objects in the store.
@@ -88,11 +120,5 @@ func (s *ObjectStore) GetIterator() iter.Seq2[generator.Object, error] {
 
 // getObjectKey returns a unique key for an object.
 func getObjectKey(obj *Object) string {
-	return fmt.Sprintf(
-		"%s|%s|%s|%s",
-		obj.GroupVersionKind().GroupVersion(),
-		obj.GroupVersionKind().Kind,
-		obj.GetNamespace(),
-		obj.GetName(),
-	)
+	return generator.ObjectKey(obj)
 }
@@ -247,6 +247,34 @@ metadata:
 	}
 }
 
+func TestObjectStore_AddYamlWithAnnotations(t *testing.T) {
+	yaml := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm-1
+  annotations:
+    existing: value
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret-1
+`
+	store := NewObjectStore()
+	err := store.AddYamlWithAnnotations([]byte(yaml), map[string]string{"source": "test.yaml"})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		map[string]string{"existing": "value", "source": "test.yaml"},
+		(*store)["v1|ConfigMap||cm-1"].GetAnnotations(),
+	)
+	assert.Equal(t,
+		map[string]string{"source": "test.yaml"},
+		(*store)["v1|Secret||secret-1"].GetAnnotations(),
+	)
+}
+
 func TestObjectStore_GetIterator(t *testing.T) {
 	tests := map[string]struct {
 		objects       []*Object
@@ -3,42 +3,123 @@ package v1alpha1
 import (
 	"crypto/sha256"
 	"fmt"
-	"io"
 	"iter"
-	"net/http"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
+	"cuelang.org/go/cue"
 	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"github.com/amir-ahmad/kogen/internal/cache"
 	"github.com/amir-ahmad/kogen/internal/generator"
 	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/amir-ahmad/kogen/internal/git"
 	"github.com/amir-ahmad/kogen/internal/helm"
+	"github.com/amir-ahmad/kogen/internal/sops"
+	godigest "github.com/opencontainers/go-digest"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // Generator implements generator.Generator.
 type Generator struct {
 	spec        v1alpha1.CogSpec
+	label       string
 	instanceDir string
+	moduleRoot  string
+
+	// postRenderers are the post renderers of each chart in spec.Helm.
+	postRenderers []postRenderer
+	// valuesPositions are the positions of the values of each chart in spec.Helm.
+	valuesPositions []valuesPositions
+	// resourceTimeout is how long each attempt to fetch an http resource can take.
+	resourceTimeout time.Duration
 }
 
 // Compile time check to ensure Generator implements generator.Generator.
 var _ generator.Generator = (*Generator)(nil)
 
+// Compile time check to ensure Generator implements generator.Fetcher.
+var _ generator.Fetcher = (*Generator)(nil)
+
+// Compile time check to ensure Generator implements generator.KubeVersioner.
+var _ generator.KubeVersioner = (*Generator)(nil)
+
 func NewGenerator(input generator.GeneratorInput) (generator.Generator, error) {
 	var spec v1alpha1.CogSpec
 	if err := input.Spec.Decode(&spec); err != nil {
 		return nil, fmt.Errorf("when decoding cog spec: %w", err)
 	}
 
+	for _, resource := range spec.Resource {
+		if err := validateResource(resource); err != nil {
+			return nil, err
+		}
+
+		pattern := filepath.ToSlash(resource.URL)
+		switch {
+		case isHTTPResource(resource.URL):
+			continue
+		case git.IsSource(resource.URL):
+			src, err := git.ParseSource(resource.URL)
+			if err != nil {
+				return nil, err
+			}
+			pattern = src.Path
+		}
+		if err := validateGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, pattern := range spec.ResourceOptions.Exclude {
+		if err := validateGlob(pattern); err != nil {
+			return nil, fmt.Errorf("when reading resource exclude patterns: %w", err)
+		}
+	}
+
+	resourceTimeout := cache.DefaultTimeout
+	if spec.ResourceOptions.Timeout != "" {
+		timeout, err := time.ParseDuration(spec.ResourceOptions.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid resource timeout %q: must be a positive duration such as 30s", spec.ResourceOptions.Timeout)
+		}
+		resourceTimeout = timeout
+	}
+
+	// Post renderers are read here rather than in Generate, as cue values aren't safe to use
+	// from generators running concurrently.
+	postRenderers := make([]postRenderer, 0, len(spec.Helm))
+	positions := make([]valuesPositions, 0, len(spec.Helm))
+	for i, h := range spec.Helm {
+		p, err := newPostRenderer(h.PostRenderer, input.InstanceDir)
+		if err != nil {
+			return nil, fmt.Errorf("when reading post renderer of chart %s: %w", h.ChartName, err)
+		}
+		postRenderers = append(postRenderers, p)
+
+		values := input.Spec.LookupPath(cue.MakePath(cue.Str("helm"), cue.Index(i), cue.Str("values")))
+		positions = append(positions, newValuesPositions(values))
+	}
+
 	return &Generator{
-		spec:        spec,
-		instanceDir: input.InstanceDir,
+		spec:            spec,
+		label:           input.Label,
+		instanceDir:     input.InstanceDir,
+		moduleRoot:      input.ModuleRoot,
+		postRenderers:   postRenderers,
+		valuesPositions: positions,
+		resourceTimeout: resourceTimeout,
 	}, nil
 }
 
+// KubeVersion implements generator.KubeVersioner.
+func (g *Generator) KubeVersion() string {
+	return g.spec.HelmOptions.KubeVersion
+}
+
 func isZero[T any](v T) bool {
 	return reflect.ValueOf(v).IsZero()
 }
@@ -47,21 +128,52 @@ func isZero[T any](v T) bool {
 func (g *Generator) Generate(
 	options generator.Options,
 ) (iter.Seq2[generator.Object, error], error) {
+	// Commands run with kogen's permissions, so config alone can't enable them.
+	for i, h := range g.spec.Helm {
+		if g.postRenderers[i].hasCommand() && !options.AllowPostRenderCommands {
+			return nil, fmt.Errorf(
+				"helm chart %s has a postRenderer command, which only runs with "+
+					"--allow-post-render-commands or KOGEN_ALLOW_POST_RENDER_COMMANDS set",
+				h.ReleaseName,
+			)
+		}
+	}
+
 	st := store.NewObjectStore()
 
 	for _, resource := range g.spec.Resource {
-		if err := addResourceObjects(st, resource, g.instanceDir, filepath.Join(options.CacheDir, "resources")); err != nil {
+		request, err := g.resourceRequest(resource)
+		if err != nil {
+			return nil, err
+		}
+		if err := addResourceObjects(
+			st,
+			resource.URL,
+			request,
+			g.instanceDir,
+			filepath.Join(options.CacheDir, "resources"),
+			filepath.Join(options.CacheDir, "git"),
+			options.CachePolicy,
+			g.spec.ResourceOptions,
+			options.Provenance,
+		); err != nil {
 			return nil, err
 		}
 	}
 
-	for _, h := range g.spec.Helm {
+	for i, h := range g.spec.Helm {
 		if err := addHelmObjects(
 			st,
 			h,
+			g.postRenderers[i],
+			g.valuesPositions[i],
 			g.spec.HelmOptions,
 			filepath.Join(options.CacheDir, "helm"),
+			filepath.Join(options.CacheDir, "git"),
+			options.CachePolicy,
 			g.instanceDir,
+			g.moduleRoot,
+			options.Provenance,
 		); err != nil {
 			return nil, err
 		}
@@ -70,31 +182,245 @@ func (g *Generator) Generate(
 	var err error
 	// Replace store with kustomize.
 	if !isZero(g.spec.Kustomize) {
-		st, err = processStoreWithKustomize(st, g.spec.Kustomize)
+		st, err = processStoreWithKustomize(st, g.spec.Kustomize, g.instanceDir, g.moduleRoot)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// The generator is annotated last as kustomize can replace the objects in the store.
+	if options.Provenance {
+		for _, obj := range *st {
+			store.SetAnnotations(obj.Unstructured, map[string]string{
+				generator.AnnotationGenerator: g.label,
+			})
+		}
+	}
+
 	return st.GetIterator(), nil
 }
 
+// Artifacts implements generator.Fetcher.
+func (g *Generator) Artifacts(options generator.Options) ([]generator.Artifact, error) {
+	var artifacts []generator.Artifact
+
+	resourceCacheDir := filepath.Join(options.CacheDir, "resources")
+	gitCacheDir := filepath.Join(options.CacheDir, "git")
+	for _, resource := range g.spec.Resource {
+		if git.IsSource(resource.URL) {
+			artifact, err := gitArtifact("resource:"+resource.URL, resource.URL, gitCacheDir, options.CachePolicy)
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, artifact)
+			continue
+		}
+		if !isHTTPResource(resource.URL) {
+			continue
+		}
+
+		request, err := g.resourceRequest(resource)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, generator.Artifact{
+			Name:   "resource:" + resource.URL,
+			Cached: options.CachePolicy.IsCached(request, resourceCacheFile(resource.URL, resourceCacheDir)),
+			Fetch: func() (string, error) {
+				data, err := getHTTPResource(request, resourceCacheDir, options.CachePolicy)
+				if err != nil {
+					return "", err
+				}
+				return godigest.FromBytes(data).String(), nil
+			},
+		})
+	}
+
+	helmCacheDir := filepath.Join(options.CacheDir, "helm")
+	for _, h := range g.spec.Helm {
+		chart := newChart(h, g.instanceDir)
+		if git.IsSource(h.Repository) {
+			artifact, err := gitArtifact(helmSource(chart), h.Repository, gitCacheDir, options.CachePolicy)
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, artifact)
+			continue
+		}
+		if chart.GetChartType() == helm.ChartTypeLocal {
+			continue
+		}
+
+		artifacts = append(artifacts, generator.Artifact{
+			Name:   helmSource(chart),
+			Cached: chart.IsCached(helmCacheDir),
+			Fetch: func() (string, error) {
+				if _, err := chart.DownloadChart(helmCacheDir); err != nil {
+					return "", err
+				}
+				return chart.Digest(helmCacheDir)
+			},
+		})
+	}
+
+	return artifacts, nil
+}
+
+// gitArtifact returns the artifact of a git source, whose digest is the commit it's checked
+// out at.
+func gitArtifact(
+	name string,
+	source string,
+	cacheDir string,
+	policy cache.Policy,
+) (generator.Artifact, error) {
+	src, err := git.ParseSource(source)
+	if err != nil {
+		return generator.Artifact{}, err
+	}
+
+	return generator.Artifact{
+		Name:   name,
+		Cached: src.IsCached(cacheDir, policy),
+		Fetch: func() (string, error) {
+			_, commit, err := src.Checkout(cacheDir, policy)
+			if err != nil {
+				return "", fmt.Errorf("when checking out %s: %w", source, err)
+			}
+			return "git:" + commit, nil
+		},
+	}, nil
+}
+
+// checkoutGitSource checks out a git source into cacheDir and returns the path of the file
+// or directory it refers to.
+func checkoutGitSource(source string, cacheDir string, policy cache.Policy) (string, error) {
+	src, err := git.ParseSource(source)
+	if err != nil {
+		return "", err
+	}
+
+	path, _, err := src.Checkout(cacheDir, policy)
+	if err != nil {
+		return "", fmt.Errorf("when checking out %s: %w", source, err)
+	}
+	return path, nil
+}
+
+// newChart returns the helm chart to download for a HelmChart. Files in its auth settings are
+// resolved relative to instanceDir.
+func newChart(helmChart v1alpha1.HelmChart, instanceDir string) helm.Chart {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(instanceDir, path)
+	}
+
+	return helm.Chart{
+		Repository: helmChart.Repository,
+		ChartName:  helmChart.ChartName,
+		Version:    helmChart.Version,
+		Auth: helm.Auth{
+			Username:              helmChart.Auth.Username,
+			Password:              helmChart.Auth.Password,
+			Token:                 helmChart.Auth.Token,
+			CAFile:                resolve(helmChart.Auth.CAFile),
+			CertFile:              resolve(helmChart.Auth.CertFile),
+			KeyFile:               resolve(helmChart.Auth.KeyFile),
+			InsecureSkipTLSVerify: helmChart.Auth.InsecureSkipTLSVerify,
+		},
+	}
+}
+
+// chartPrefix is the prefix of values files that are inside the chart.
+const chartPrefix = "chart://"
+
+// resolveValuesFile returns the path of a values file. Files prefixed with chart:// are
+// relative to the chart and files prefixed with module:// are relative to the module root, and
+// must be inside them. Other files are relative to the instance.
+func resolveValuesFile(valuesFile, chartDir, instanceDir, moduleRoot string) (string, error) {
+	switch {
+	case strings.HasPrefix(valuesFile, chartPrefix):
+		path := filepath.FromSlash(strings.TrimPrefix(valuesFile, chartPrefix))
+		if !filepath.IsLocal(path) {
+			return "", fmt.Errorf("values file %s is not within the chart", valuesFile)
+		}
+		return filepath.Join(chartDir, path), nil
+	case strings.HasPrefix(valuesFile, sops.ModulePrefix):
+		if moduleRoot == "" {
+			return "", fmt.Errorf("values file %s requires a cue module", valuesFile)
+		}
+		path := filepath.FromSlash(strings.TrimPrefix(valuesFile, sops.ModulePrefix))
+		if !filepath.IsLocal(path) {
+			return "", fmt.Errorf("values file %s is not within the module root", valuesFile)
+		}
+		return filepath.Join(moduleRoot, path), nil
+	case filepath.IsAbs(valuesFile):
+		return valuesFile, nil
+	default:
+		return filepath.Join(instanceDir, valuesFile), nil
+	}
+}
+
+// sourceAnnotations returns the annotations recording the source of objects, or nil when
+// provenance is disabled.
+func sourceAnnotations(provenance bool, source string) map[string]string {
+	if !provenance {
+		return nil
+	}
+	return map[string]string{generator.AnnotationSource: source}
+}
+
+// helmSource returns a description of a helm chart for the source annotation.
+func helmSource(chart helm.Chart) string {
+	switch chart.GetChartType() {
+	case helm.ChartTypeOCI:
+		return fmt.Sprintf("helm:%s@%s", chart.Repository, chart.Version)
+	case helm.ChartTypeHTTP:
+		return fmt.Sprintf("helm:%s/%s@%s", strings.TrimSuffix(chart.Repository, "/"), chart.ChartName, chart.Version)
+	default:
+		return "helm:" + strings.TrimSuffix(chart.Repository, "/")
+	}
+}
+
+// templateFile returns the path of the template within the chart that a rendered template
+// key refers to. Keys are prefixed with the chart name and suffixed with the index of the
+// manifest within the template.
+func templateFile(key string) string {
+	if i := strings.LastIndex(key, "-manifest-"); i >= 0 {
+		key = key[:i]
+	}
+	if _, file, ok := strings.Cut(key, "/"); ok {
+		return file
+	}
+	return key
+}
+
 func addHelmObjects(
 	st *store.ObjectStore,
 	helmChart v1alpha1.HelmChart,
+	postRenderer postRenderer,
+	valuesPositions valuesPositions,
 	helmOptions v1alpha1.HelmOptions,
 	cacheDir string,
+	gitCacheDir string,
+	cachePolicy cache.Policy,
 	instanceDir string,
+	moduleRoot string,
+	provenance bool,
 ) error {
-	chart := helm.Chart{
-		Repository: helmChart.Repository,
-		ChartName:  helmChart.ChartName,
-		Version:    helmChart.Version,
-	}
+	chart := newChart(helmChart, instanceDir)
 
 	chartDir := filepath.Join(instanceDir, helmChart.Repository)
 	var err error
-	if chart.GetChartType() != helm.ChartTypeLocal {
+	switch {
+	case git.IsSource(helmChart.Repository):
+		chartDir, err = checkoutGitSource(helmChart.Repository, gitCacheDir, cachePolicy)
+		if err != nil {
+			return fmt.Errorf("when checking out chart: %w", err)
+		}
+	case chart.GetChartType() != helm.ChartTypeLocal:
 		chartDir, err = chart.DownloadChart(cacheDir)
 		if err != nil {
 			return fmt.Errorf("when downloading chart: %w", err)
@@ -112,27 +438,75 @@ func addHelmObjects(
 				},
 			},
 		}
+		if provenance {
+			store.SetAnnotations(namespace, sourceAnnotations(provenance, helmSource(chart)))
+		}
 		if err := st.Add(&store.Object{Unstructured: namespace}); err != nil {
 			return fmt.Errorf("when adding namespace object to store: %w", err)
 		}
 	}
 
+	valuesFiles := make([]string, 0, len(helmChart.ValuesFiles))
+	for _, valuesFile := range helmChart.ValuesFiles {
+		path, err := resolveValuesFile(valuesFile, chartDir, instanceDir, moduleRoot)
+		if err != nil {
+			return err
+		}
+		valuesFiles = append(valuesFiles, path)
+	}
+
+	lookupDir := helmOptions.LookupDir
+	if lookupDir != "" && !filepath.IsAbs(lookupDir) {
+		lookupDir = filepath.Join(instanceDir, lookupDir)
+	}
+
 	release := helm.Release{
 		Release:     helmChart.ReleaseName,
 		Namespace:   helmChart.Namespace,
 		IncludeCRDs: helmChart.IncludeCRDs,
 		Values:      helmChart.Values,
+		ValuesFiles: valuesFiles,
 		KubeVersion: helmOptions.KubeVersion,
 		APIVersions: helmOptions.APIVersions,
+		LookupDir:   lookupDir,
 	}
 
 	renderedTemplates, err := release.Template(chartDir)
 	if err != nil {
+		err = withValuesPositions(err, valuesPositions, instanceDir)
 		return fmt.Errorf("when rendering helm templates: %w", err)
 	}
 
+	// The output of a command can't be traced back to templates, so it's attributed to the chart.
+	if postRenderer.hasCommand() {
+		output, err := postRenderer.runCommand(renderedTemplates)
+		if err != nil {
+			return err
+		}
+		objects, err := store.DecodeYaml(output)
+		if err != nil {
+			return fmt.Errorf("when decoding post rendered helm objects: %w", err)
+		}
+		annotations := sourceAnnotations(provenance, helmSource(chart))
+		if err := addRenderedObjects(st, objects, helmChart, annotations); err != nil {
+			return fmt.Errorf("when adding post rendered helm objects to store: %w", err)
+		}
+		return nil
+	}
+
 	for k, v := range renderedTemplates {
-		if err := st.AddYaml([]byte(v)); err != nil {
+		objects, err := store.DecodeYaml([]byte(v))
+		if err != nil {
+			return fmt.Errorf("when decoding helm objects from %s: %w", k, err)
+		}
+		if postRenderer.hasTransform() {
+			objects, err = postRenderer.transformObjects(objects)
+			if err != nil {
+				return fmt.Errorf("when post rendering helm objects from %s: %w", k, err)
+			}
+		}
+		annotations := sourceAnnotations(provenance, helmSource(chart)+":"+templateFile(k))
+		if err := addRenderedObjects(st, objects, helmChart, annotations); err != nil {
 			return fmt.Errorf("when adding helm objects to store from %s: %w", k, err)
 		}
 	}
@@ -144,33 +518,60 @@ func addHelmObjects(
 func addResourceObjects(
 	st *store.ObjectStore,
 	resource string,
+	request cache.Request,
 	instanceDir string,
 	cacheDir string,
+	gitCacheDir string,
+	cachePolicy cache.Policy,
+	resourceOptions v1alpha1.ResourceOptions,
+	provenance bool,
 ) error {
 	var yamlData []byte
 	var err error
 
-	if strings.HasPrefix(resource, "http://") || strings.HasPrefix(resource, "https://") {
+	if isHTTPResource(resource) {
 		// Handle http yaml files with caching
-		yamlData, err = getHTTPResource(resource, cacheDir)
+		yamlData, err = getHTTPResource(request, cacheDir, cachePolicy)
 		if err != nil {
 			return fmt.Errorf("when getting cached resource from URL %s: %w", resource, err)
 		}
 	} else {
-		// Handle local file/directory resources
-		resourcePath := resource
-		if !filepath.IsAbs(resource) {
-			resourcePath = filepath.Join(instanceDir, resource)
+		// Handle local file/directory resources, including those checked out from git. Globs
+		// are detected in the path as written, as the directory it's relative to can contain
+		// wildcard characters too.
+		var baseDir string
+		pattern := filepath.ToSlash(resource)
+		switch {
+		case git.IsSource(resource):
+			src, err := git.ParseSource(resource)
+			if err != nil {
+				return err
+			}
+			pattern = src.Path
+			src.Path = ""
+			baseDir, _, err = src.Checkout(gitCacheDir, cachePolicy)
+			if err != nil {
+				return fmt.Errorf("when getting resource from git: when checking out %s: %w", resource, err)
+			}
+		case !filepath.IsAbs(resource):
+			baseDir = instanceDir
 		}
 
+		if isGlob(pattern) {
+			dir, rel := splitGlob(pattern)
+			dir = filepath.Join(baseDir, filepath.FromSlash(dir))
+			return addResourceFiles(st, dir, rel, resource, resourceOptions, provenance)
+		}
+
+		resourcePath := filepath.Join(baseDir, filepath.FromSlash(pattern))
 		info, err := os.Stat(resourcePath)
 		if err != nil {
 			return fmt.Errorf("when accessing resource %s: %w", resourcePath, err)
 		}
 
 		if info.IsDir() {
-			// Handle directory - read all yaml files
-			return addResourceDirectory(st, resourcePath)
+			// Handle directory - read all manifest files
+			return addResourceFiles(st, resourcePath, "", resource, resourceOptions, provenance)
 		} else {
 			// Handle single file
 			yamlData, err = os.ReadFile(resourcePath)
@@ -180,87 +581,99 @@ func addResourceObjects(
 		}
 	}
 
-	if err := st.AddYaml(yamlData); err != nil {
+	annotations := sourceAnnotations(provenance, "resource:"+resource)
+	if err := st.AddYamlWithAnnotations(yamlData, annotations); err != nil {
 		return fmt.Errorf("when adding resource objects to store: %w", err)
 	}
 
 	return nil
 }
 
-// getHTTPResource fetches a resource from a remote URL and caches it.
-func getHTTPResource(url string, cacheDir string) ([]byte, error) {
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return nil, fmt.Errorf("when creating cache directory: %w", err)
-	}
+// isHTTPResource returns whether a resource is fetched from a remote URL.
+func isHTTPResource(resource string) bool {
+	return strings.HasPrefix(resource, "http://") || strings.HasPrefix(resource, "https://")
+}
 
-	// Generate cache key from URL, with first 8 bytes.
+// resourceCacheFile returns the path a remote resource is cached at.
+func resourceCacheFile(url string, cacheDir string) string {
+	// Generate cache key from the hash of the URL.
 	hash := sha256.Sum256([]byte(url))
-	cacheKey := fmt.Sprintf("%x", hash[:8])
-	cacheFile := filepath.Join(cacheDir, cacheKey+".yaml")
+	return filepath.Join(cacheDir, fmt.Sprintf("%x.yaml", hash))
+}
 
-	// Read from cached file when it exists
-	if _, err := os.Stat(cacheFile); err == nil {
-		data, err := os.ReadFile(cacheFile)
-		if err != nil {
-			return nil, fmt.Errorf("when reading cached resource: %w", err)
-		}
-		return data, nil
-	}
+// getHTTPResource fetches a resource from a remote URL and caches it.
+func getHTTPResource(request cache.Request, cacheDir string, policy cache.Policy) ([]byte, error) {
+	return cache.Download(request, resourceCacheFile(request.URL, cacheDir), policy)
+}
 
-	// Fetch from remote if not cached
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("when fetching resource from URL %s: %w", url, err)
+// validateResource returns an error if a resource sets options that only apply to http
+// resources without being one, or has an invalid sha256.
+func validateResource(resource v1alpha1.Resource) error {
+	if isHTTPResource(resource.URL) {
+		if resource.SHA256 != "" && !sha256Pattern.MatchString(resource.SHA256) {
+			return fmt.Errorf("sha256 of resource %s must be 64 hex characters", resource.URL)
+		}
+		return nil
 	}
-	defer resp.Body.Close() //nolint:errcheck
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf(
-			"failed to fetch resource from URL %s: status %d",
-			url,
-			resp.StatusCode,
-		)
+	if resource.SHA256 != "" || len(resource.Headers) > 0 || len(resource.HeadersFromEnv) > 0 {
+		return fmt.Errorf("resource %s sets sha256 or headers, which are only supported for http resources", resource.URL)
 	}
+	return nil
+}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("when reading resource from URL %s: %w", url, err)
+// sha256Pattern matches a sha256 digest in hex.
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// resourceRequest returns the request to fetch an http resource with. Headers are read from
+// the environment when the resource is fetched, rather than when the config is loaded.
+func (g *Generator) resourceRequest(resource v1alpha1.Resource) (cache.Request, error) {
+	headers := maps.Clone(resource.Headers)
+	for header, env := range resource.HeadersFromEnv {
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return cache.Request{}, fmt.Errorf(
+				"environment variable %s for header %s of resource %s is not set",
+				env,
+				header,
+				resource.URL,
+			)
+		}
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[header] = value
 	}
 
-	if err := os.WriteFile(cacheFile, data, 0o644); err != nil {
-		return nil, fmt.Errorf("when caching resource from URL %s: %w", url, err)
+	retries := cache.DefaultRetries
+	if g.spec.ResourceOptions.Retries != nil {
+		retries = *g.spec.ResourceOptions.Retries
 	}
 
-	return data, nil
+	return cache.Request{
+		URL:     resource.URL,
+		Headers: headers,
+		SHA256:  resource.SHA256,
+		Timeout: g.resourceTimeout,
+		Retries: retries,
+	}, nil
 }
 
-// addResourceDirectory adds all yaml files in a directory to the store.
-func addResourceDirectory(st *store.ObjectStore, dirPath string) error {
-	entries, err := os.ReadDir(dirPath)
-	if err != nil {
-		return fmt.Errorf("unable to read directory %s: %w", dirPath, err)
-	}
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		ext := strings.ToLower(filepath.Ext(entry.Name()))
-		if ext != ".yaml" && ext != ".yml" {
-			continue
-		}
-
-		fullPath := filepath.Join(dirPath, entry.Name())
-		yamlData, err := os.ReadFile(fullPath)
-		if err != nil {
-			return fmt.Errorf("when reading resource file %s: %w", fullPath, err)
+// addRenderedObjects adds the objects rendered by a chart to the store, after processing their
+// hooks.
+func addRenderedObjects(
+	st *store.ObjectStore,
+	objects []*unstructured.Unstructured,
+	helmChart v1alpha1.HelmChart,
+	annotations map[string]string,
+) error {
+	for _, obj := range processHooks(objects, helmChart) {
+		if len(annotations) > 0 {
+			store.SetAnnotations(obj, annotations)
 		}
-
-		if err := st.AddYaml(yamlData); err != nil {
-			return fmt.Errorf("when adding resource objects from %s to store: %w", fullPath, err)
+		if err := st.Add(&store.Object{Unstructured: obj}); err != nil {
+			return err
 		}
 	}
-
 	return nil
 }
@@ -0,0 +1,129 @@
+package v1alpha1
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	helmHookAnnotation             = "helm.sh/hook"
+	helmHookWeightAnnotation       = "helm.sh/hook-weight"
+	helmHookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+
+	argoCDHookAnnotation             = "argocd.argoproj.io/hook"
+	argoCDHookDeletePolicyAnnotation = "argocd.argoproj.io/hook-delete-policy"
+	argoCDSyncWaveAnnotation         = "argocd.argoproj.io/sync-wave"
+)
+
+// helmTestHooks are the hooks run by helm test.
+var helmTestHooks = []string{"test", "test-success", "test-failure"}
+
+// argoCDHooks maps helm hooks to their Argo CD equivalents. Hooks that aren't in the map have
+// no equivalent.
+var argoCDHooks = map[string]string{
+	"pre-install":  "PreSync",
+	"pre-upgrade":  "PreSync",
+	"post-install": "PostSync",
+	"post-upgrade": "PostSync",
+	"post-delete":  "PostDelete",
+}
+
+// argoCDDeletePolicies maps helm hook delete policies to their Argo CD equivalents.
+var argoCDDeletePolicies = map[string]string{
+	"before-hook-creation": "BeforeHookCreation",
+	"hook-succeeded":       "HookSucceeded",
+	"hook-failed":          "HookFailed",
+}
+
+// processHooks removes the hooks and tests that the chart skips, and translates the remaining
+// hooks to Argo CD hooks when enabled.
+func processHooks(
+	objects []*unstructured.Unstructured,
+	helmChart v1alpha1.HelmChart,
+) []*unstructured.Unstructured {
+	if !helmChart.SkipHooks && !helmChart.SkipTests && !helmChart.ArgoCDHooks {
+		return objects
+	}
+
+	var processed []*unstructured.Unstructured
+	for _, obj := range objects {
+		hooks := splitAnnotation(obj.GetAnnotations()[helmHookAnnotation])
+		if len(hooks) == 0 {
+			processed = append(processed, obj)
+			continue
+		}
+
+		if helmChart.SkipHooks {
+			continue
+		}
+
+		isTest := slices.ContainsFunc(hooks, func(hook string) bool {
+			return slices.Contains(helmTestHooks, hook)
+		})
+		if helmChart.SkipTests && isTest {
+			continue
+		}
+
+		if helmChart.ArgoCDHooks && !translateHooks(obj, hooks) {
+			continue
+		}
+
+		processed = append(processed, obj)
+	}
+
+	return processed
+}
+
+// translateHooks replaces the helm hook annotations of an object with Argo CD hook annotations.
+// It returns false when none of the hooks have an Argo CD equivalent, in which case the object
+// would never be run by Argo CD during a sync and is left out.
+func translateHooks(obj *unstructured.Unstructured, hooks []string) bool {
+	var argoHooks []string
+	for _, hook := range hooks {
+		if argoHook, ok := argoCDHooks[hook]; ok && !slices.Contains(argoHooks, argoHook) {
+			argoHooks = append(argoHooks, argoHook)
+		}
+	}
+	if len(argoHooks) == 0 {
+		return false
+	}
+
+	annotations := obj.GetAnnotations()
+
+	var deletePolicies []string
+	for _, policy := range splitAnnotation(annotations[helmHookDeletePolicyAnnotation]) {
+		if argoPolicy, ok := argoCDDeletePolicies[policy]; ok {
+			deletePolicies = append(deletePolicies, argoPolicy)
+		}
+	}
+	weight := strings.TrimSpace(annotations[helmHookWeightAnnotation])
+
+	delete(annotations, helmHookAnnotation)
+	delete(annotations, helmHookDeletePolicyAnnotation)
+	delete(annotations, helmHookWeightAnnotation)
+
+	annotations[argoCDHookAnnotation] = strings.Join(argoHooks, ",")
+	if len(deletePolicies) > 0 {
+		annotations[argoCDHookDeletePolicyAnnotation] = strings.Join(deletePolicies, ",")
+	}
+	if weight != "" {
+		annotations[argoCDSyncWaveAnnotation] = weight
+	}
+
+	obj.SetAnnotations(annotations)
+	return true
+}
+
+// splitAnnotation splits a comma separated annotation value.
+func splitAnnotation(value string) []string {
+	var values []string
+	for v := range strings.SplitSeq(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
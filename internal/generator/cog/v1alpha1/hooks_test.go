@@ -0,0 +1,105 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newHookObject(name string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("batch/v1")
+	obj.SetKind("Job")
+	obj.SetName(name)
+	if annotations != nil {
+		obj.SetAnnotations(annotations)
+	}
+	return obj
+}
+
+func TestProcessHooks(t *testing.T) {
+	tests := map[string]struct {
+		helmChart v1alpha1.HelmChart
+		objects   []*unstructured.Unstructured
+		expected  []*unstructured.Unstructured
+	}{
+		"hooks are kept by default": {
+			objects: []*unstructured.Unstructured{
+				newHookObject("hook", map[string]string{"helm.sh/hook": "post-install"}),
+				newHookObject("test", map[string]string{"helm.sh/hook": "test"}),
+			},
+			expected: []*unstructured.Unstructured{
+				newHookObject("hook", map[string]string{"helm.sh/hook": "post-install"}),
+				newHookObject("test", map[string]string{"helm.sh/hook": "test"}),
+			},
+		},
+		"skip hooks": {
+			helmChart: v1alpha1.HelmChart{SkipHooks: true},
+			objects: []*unstructured.Unstructured{
+				newHookObject("plain", nil),
+				newHookObject("hook", map[string]string{"helm.sh/hook": "post-install"}),
+				newHookObject("test", map[string]string{"helm.sh/hook": "test"}),
+			},
+			expected: []*unstructured.Unstructured{
+				newHookObject("plain", nil),
+			},
+		},
+		"skip tests": {
+			helmChart: v1alpha1.HelmChart{SkipTests: true},
+			objects: []*unstructured.Unstructured{
+				newHookObject("hook", map[string]string{"helm.sh/hook": "post-install"}),
+				newHookObject("test", map[string]string{"helm.sh/hook": "test"}),
+				newHookObject("legacy-test", map[string]string{"helm.sh/hook": "test-success"}),
+			},
+			expected: []*unstructured.Unstructured{
+				newHookObject("hook", map[string]string{"helm.sh/hook": "post-install"}),
+			},
+		},
+		"translate to argo cd hooks": {
+			helmChart: v1alpha1.HelmChart{ArgoCDHooks: true},
+			objects: []*unstructured.Unstructured{
+				newHookObject("plain", nil),
+				newHookObject("hook", map[string]string{
+					"helm.sh/hook":               "pre-install, pre-upgrade,post-install",
+					"helm.sh/hook-delete-policy": "before-hook-creation,hook-succeeded",
+					"helm.sh/hook-weight":        "-5",
+					"other":                      "kept",
+				}),
+				newHookObject("cleanup", map[string]string{
+					"helm.sh/hook": "post-delete",
+				}),
+			},
+			expected: []*unstructured.Unstructured{
+				newHookObject("plain", nil),
+				newHookObject("hook", map[string]string{
+					"argocd.argoproj.io/hook":               "PreSync,PostSync",
+					"argocd.argoproj.io/hook-delete-policy": "BeforeHookCreation,HookSucceeded",
+					"argocd.argoproj.io/sync-wave":          "-5",
+					"other":                                 "kept",
+				}),
+				newHookObject("cleanup", map[string]string{
+					"argocd.argoproj.io/hook": "PostDelete",
+				}),
+			},
+		},
+		"hooks without an argo cd equivalent are left out, including tests": {
+			helmChart: v1alpha1.HelmChart{ArgoCDHooks: true, SkipTests: false},
+			objects: []*unstructured.Unstructured{
+				newHookObject("test", map[string]string{"helm.sh/hook": "test"}),
+				newHookObject("rollback", map[string]string{"helm.sh/hook": "pre-rollback,post-rollback"}),
+				newHookObject("mixed", map[string]string{"helm.sh/hook": "pre-delete,post-upgrade"}),
+			},
+			expected: []*unstructured.Unstructured{
+				newHookObject("mixed", map[string]string{"argocd.argoproj.io/hook": "PostSync"}),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, processHooks(tc.objects, tc.helmChart))
+		})
+	}
+}
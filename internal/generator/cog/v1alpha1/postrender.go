@@ -0,0 +1,195 @@
+package v1alpha1
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	transformInPath  = cue.MakePath(cue.Def("#in"))
+	transformOutPath = cue.MakePath(cue.Def("#out"))
+)
+
+// postRenderer transforms the manifests rendered by a helm chart before they are added to the
+// store.
+type postRenderer struct {
+	// command is run with the rendered manifests on stdin.
+	command []string
+	// dir is the directory the command is run in.
+	dir string
+
+	// transform is the source of a cue transformation applied to each object. It is compiled
+	// when used, as cue values can't be shared between generators running concurrently.
+	transform []byte
+}
+
+// newPostRenderer reads the post renderer of a helm chart.
+func newPostRenderer(spec v1alpha1.HelmPostRenderer, instanceDir string) (postRenderer, error) {
+	hasTransform := spec.Transform.Exists()
+	if len(spec.Command) > 0 && hasTransform {
+		return postRenderer{}, fmt.Errorf("postRenderer can't have both a command and a transform")
+	}
+
+	p := postRenderer{dir: instanceDir}
+
+	if len(spec.Command) > 0 {
+		p.command = slices.Clone(spec.Command)
+		if strings.ContainsRune(p.command[0], '/') && !filepath.IsAbs(p.command[0]) {
+			p.command[0] = filepath.Join(instanceDir, p.command[0])
+		}
+	}
+
+	if hasTransform {
+		if !spec.Transform.LookupPath(transformOutPath).Exists() {
+			return postRenderer{}, fmt.Errorf("postRenderer transform must have a #out field")
+		}
+
+		// Imports and references to the rest of the config are inlined so that the transform
+		// can be compiled on its own.
+		src, err := format.Node(spec.Transform.Syntax(
+			cue.Docs(true),
+			cue.Definitions(true),
+			cue.Hidden(true),
+			cue.InlineImports(true),
+		))
+		if err != nil {
+			return postRenderer{}, fmt.Errorf("failed to format postRenderer transform: %w", err)
+		}
+		p.transform = src
+	}
+
+	return p, nil
+}
+
+// hasCommand returns whether the post renderer runs a command.
+func (p postRenderer) hasCommand() bool {
+	return len(p.command) > 0
+}
+
+// hasTransform returns whether the post renderer applies a cue transformation.
+func (p postRenderer) hasTransform() bool {
+	return len(p.transform) > 0
+}
+
+// runCommand runs the command with the rendered templates on stdin, and returns its output.
+func (p postRenderer) runCommand(renderedTemplates map[string]string) ([]byte, error) {
+	var stdin bytes.Buffer
+	for _, key := range slices.Sorted(maps.Keys(renderedTemplates)) {
+		stdin.WriteString("---\n")
+		stdin.WriteString(renderedTemplates[key])
+		if !strings.HasSuffix(renderedTemplates[key], "\n") {
+			stdin.WriteString("\n")
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(p.command[0], p.command[1:]...)
+	cmd.Dir = p.dir
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(
+			"when running post renderer %s: %w: %s",
+			p.command[0],
+			err,
+			strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// transformObjects applies the cue transformation to each object.
+func (p postRenderer) transformObjects(
+	objects []*unstructured.Unstructured,
+) ([]*unstructured.Unstructured, error) {
+	transform := cuecontext.New().CompileBytes(p.transform)
+	if err := transform.Err(); err != nil {
+		return nil, fmt.Errorf("failed to compile postRenderer transform: %w", err)
+	}
+
+	var transformed []*unstructured.Unstructured
+	for _, obj := range objects {
+		out := transform.FillPath(transformInPath, obj.Object).LookupPath(transformOutPath)
+		if err := out.Validate(cue.Concrete(true)); err != nil {
+			return nil, fmt.Errorf(
+				"when transforming %s %s: %w",
+				obj.GetKind(),
+				obj.GetName(),
+				err,
+			)
+		}
+
+		outObjects, err := decodeTransformOutput(out)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"when transforming %s %s: %w",
+				obj.GetKind(),
+				obj.GetName(),
+				err,
+			)
+		}
+		transformed = append(transformed, outObjects...)
+	}
+
+	return transformed, nil
+}
+
+// decodeTransformOutput decodes the #out field of a transformation, which is an object, a list
+// of objects, or null.
+func decodeTransformOutput(out cue.Value) ([]*unstructured.Unstructured, error) {
+	switch out.Kind() {
+	case cue.NullKind:
+		return nil, nil
+	case cue.StructKind:
+		obj, err := decodeObject(out)
+		if err != nil {
+			return nil, err
+		}
+		return []*unstructured.Unstructured{obj}, nil
+	case cue.ListKind:
+		iter, err := out.List()
+		if err != nil {
+			return nil, err
+		}
+
+		var objects []*unstructured.Unstructured
+		for iter.Next() {
+			obj, err := decodeObject(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, obj)
+		}
+		return objects, nil
+	default:
+		return nil, fmt.Errorf("#out must be an object, a list of objects or null, not %s", out.Kind())
+	}
+}
+
+// decodeObject decodes a cue struct into an object.
+func decodeObject(v cue.Value) (*unstructured.Unstructured, error) {
+	jsonBytes, err := v.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode object to json: %w", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(jsonBytes); err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	return obj, nil
+}
@@ -0,0 +1,204 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/amir-ahmad/kogen/internal/git"
+)
+
+// manifestExtensions are the extensions of the files read from resource directories and globs.
+var manifestExtensions = []string{".json", ".yaml", ".yml"}
+
+// isGlob returns whether a resource path is a glob pattern.
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// splitGlob splits a slash separated glob pattern into the directory before its first
+// wildcard and the pattern of the paths relative to that directory.
+func splitGlob(pattern string) (string, string) {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if isGlob(segment) {
+			dir := strings.Join(segments[:i], "/")
+			if dir == "" && i > 0 {
+				dir = "/"
+			}
+			if dir == "" {
+				dir = "."
+			}
+			return dir, strings.Join(segments[i:], "/")
+		}
+	}
+	return pattern, ""
+}
+
+// validateGlob returns an error if a slash separated glob pattern is malformed.
+func validateGlob(pattern string) error {
+	for segment := range strings.SplitSeq(pattern, "/") {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %s: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchGlob returns whether a slash separated path matches a glob pattern, in which a **
+// segment matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := range len(name) + 1 {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// isExcluded returns whether a slash separated path relative to a resource directory matches
+// any of the exclude patterns.
+func isExcluded(rel string, exclude []string) bool {
+	for _, pattern := range exclude {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceFiles returns the manifest files in dir that match pattern, a slash separated glob
+// relative to dir, or every manifest file of the directory when pattern is empty. Files are
+// returned as slash separated paths relative to dir, sorted so that the order doesn't depend on
+// the filesystem.
+func resourceFiles(dir, pattern string, options v1alpha1.ResourceOptions) ([]string, error) {
+	// Directories deeper than the pattern can't contain matches, unless it has a **.
+	depth := -1
+	if pattern != "" && !slices.Contains(strings.Split(pattern, "/"), "**") {
+		depth = strings.Count(pattern, "/")
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			switch {
+			case isExcluded(rel, options.Exclude):
+				return filepath.SkipDir
+			case pattern == "" && !options.Recursive:
+				return filepath.SkipDir
+			case depth >= 0 && strings.Count(rel, "/") >= depth:
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !slices.Contains(manifestExtensions, strings.ToLower(path.Ext(rel))) {
+			return nil
+		}
+		if pattern != "" && !matchGlob(pattern, rel) {
+			return nil
+		}
+		if isExcluded(rel, options.Exclude) {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory %s: %w", dir, err)
+	}
+
+	slices.Sort(files)
+	return files, nil
+}
+
+// resourceFileSource returns the source annotation of a file read from a resource directory
+// or glob.
+func resourceFileSource(resource string, file string) string {
+	if git.IsSource(resource) {
+		return "resource:" + resource + ":" + file
+	}
+
+	dir := filepath.ToSlash(resource)
+	if isGlob(dir) {
+		dir, _ = splitGlob(dir)
+	}
+	return "resource:" + path.Join(dir, file)
+}
+
+// addResourceFiles adds the objects of every manifest file in a resource directory, or that
+// matches the glob pattern relative to it, to the store.
+func addResourceFiles(
+	st *store.ObjectStore,
+	dir string,
+	pattern string,
+	resource string,
+	options v1alpha1.ResourceOptions,
+	provenance bool,
+) error {
+	files, err := resourceFiles(dir, pattern, options)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && pattern != "" {
+		return fmt.Errorf("resource %s matches no files", resource)
+	}
+
+	for _, file := range files {
+		fullPath := filepath.Join(dir, filepath.FromSlash(file))
+		yamlData, err := os.ReadFile(fullPath)
+		if err != nil {
+			return fmt.Errorf("when reading resource file %s: %w", fullPath, err)
+		}
+
+		annotations := sourceAnnotations(provenance, resourceFileSource(resource, file))
+		if err := st.AddYamlWithAnnotations(yamlData, annotations); err != nil {
+			return fmt.Errorf("when adding resource objects from %s to store: %w", fullPath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"github.com/amir-ahmad/kogen/internal/cache"
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := map[string]struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		"single segment":              {pattern: "*.yaml", name: "a.yaml", expected: true},
+		"wildcard doesn't cross dirs": {pattern: "*.yaml", name: "a/b.yaml", expected: false},
+		"double star matches no dirs": {pattern: "**/*.yaml", name: "a.yaml", expected: true},
+		"double star matches dirs":    {pattern: "**/*.yaml", name: "a/b/c.yaml", expected: true},
+		"double star in the middle":   {pattern: "a/**/c.yaml", name: "a/b/d/c.yaml", expected: true},
+		"trailing double star":        {pattern: "a/**", name: "a/b/c.yaml", expected: true},
+		"different prefix":            {pattern: "a/**/c.yaml", name: "b/c.yaml", expected: false},
+		"character class":             {pattern: "[ab].yaml", name: "c.yaml", expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, matchGlob(tc.pattern, tc.name))
+		})
+	}
+}
+
+func TestSplitGlob(t *testing.T) {
+	tests := map[string]struct {
+		pattern     string
+		expectedDir string
+		expectedRel string
+	}{
+		"relative":         {pattern: "manifests/**/*.yaml", expectedDir: "manifests", expectedRel: "**/*.yaml"},
+		"no directory":     {pattern: "*.yaml", expectedDir: ".", expectedRel: "*.yaml"},
+		"absolute":         {pattern: "/tmp/manifests/*.yaml", expectedDir: "/tmp/manifests", expectedRel: "*.yaml"},
+		"root":             {pattern: "/*.yaml", expectedDir: "/", expectedRel: "*.yaml"},
+		"wildcard in dirs": {pattern: "apps/*/base/*.yaml", expectedDir: "apps", expectedRel: "*/base/*.yaml"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, rel := splitGlob(tc.pattern)
+			assert.Equal(t, tc.expectedDir, dir)
+			assert.Equal(t, tc.expectedRel, rel)
+		})
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	require.NoError(t, validateGlob("manifests/**/[a-z]*.yaml"))
+	require.ErrorContains(t, validateGlob("manifests/[a-.yaml"), "invalid glob pattern manifests/[a-.yaml")
+}
+
+func TestResourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"a.yaml",
+		"b.json",
+		"README.md",
+		"sub/c.yml",
+		"sub/test/d.yaml",
+		"sub/e_test.yaml",
+		"vendor/f.yaml",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	tests := map[string]struct {
+		pattern       string
+		options       v1alpha1.ResourceOptions
+		expectedFiles []string
+	}{
+		"directory": {
+			expectedFiles: []string{"a.yaml", "b.json"},
+		},
+		"recursive directory": {
+			options:       v1alpha1.ResourceOptions{Recursive: true},
+			expectedFiles: []string{"a.yaml", "b.json", "sub/c.yml", "sub/e_test.yaml", "sub/test/d.yaml", "vendor/f.yaml"},
+		},
+		"recursive directory with excludes": {
+			options: v1alpha1.ResourceOptions{
+				Recursive: true,
+				Exclude:   []string{"*_test.yaml", "test", "vendor/**"},
+			},
+			expectedFiles: []string{"a.yaml", "b.json", "sub/c.yml"},
+		},
+		"glob": {
+			pattern:       "*",
+			expectedFiles: []string{"a.yaml", "b.json"},
+		},
+		"glob of directories": {
+			pattern:       "**/*.yaml",
+			options:       v1alpha1.ResourceOptions{Exclude: []string{"vendor"}},
+			expectedFiles: []string{"a.yaml", "sub/e_test.yaml", "sub/test/d.yaml"},
+		},
+		"glob within a directory": {
+			pattern:       "sub/*/*.yaml",
+			expectedFiles: []string{"sub/test/d.yaml"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, err := resourceFiles(dir, tc.pattern, tc.options)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedFiles, files)
+		})
+	}
+}
+
+func TestAddResourceObjects_WildcardsInInstanceDir(t *testing.T) {
+	instanceDir := filepath.Join(t.TempDir(), "app[1]")
+	require.NoError(t, os.MkdirAll(filepath.Join(instanceDir, "manifests"), 0o755))
+	for _, name := range []string{"a", "b"} {
+		manifest := fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", name)
+		require.NoError(t, os.WriteFile(filepath.Join(instanceDir, "manifests", name+".yaml"), []byte(manifest), 0o644))
+	}
+
+	tests := map[string]int{
+		"manifests":          2,
+		"manifests/a.yaml":   1,
+		"manifests/[a].yaml": 1,
+	}
+
+	for resource, expected := range tests {
+		t.Run(resource, func(t *testing.T) {
+			st := store.NewObjectStore()
+			err := addResourceObjects(
+				st,
+				resource,
+				cache.Request{},
+				instanceDir,
+				"",
+				"",
+				cache.Policy{},
+				v1alpha1.ResourceOptions{},
+				false,
+			)
+			require.NoError(t, err)
+			assert.Len(t, *st, expected)
+		})
+	}
+}
+
+func TestResourceFileSource(t *testing.T) {
+	assert.Equal(t, "resource:manifests/sub/a.yaml", resourceFileSource("manifests/", "sub/a.yaml"))
+	assert.Equal(t, "resource:manifests/sub/a.yaml", resourceFileSource("manifests/**/*.yaml", "sub/a.yaml"))
+	assert.Equal(t, "resource:a.yaml", resourceFileSource("*.yaml", "a.yaml"))
+	assert.Equal(
+		t,
+		"resource:git::https://github.com/org/repo//deploy?ref=v1:sub/a.yaml",
+		resourceFileSource("git::https://github.com/org/repo//deploy?ref=v1", "sub/a.yaml"),
+	)
+}
+
+func TestResourceRequest(t *testing.T) {
+	t.Setenv("KOGEN_TEST_TOKEN", "Bearer secret")
+	zero := 0
+
+	tests := map[string]struct {
+		resource    v1alpha1.Resource
+		options     v1alpha1.ResourceOptions
+		expected    cache.Request
+		expectError string
+	}{
+		"defaults": {
+			resource: v1alpha1.Resource{URL: "https://example.com/a.yaml"},
+			expected: cache.Request{URL: "https://example.com/a.yaml", Timeout: time.Minute, Retries: cache.DefaultRetries},
+		},
+		"headers from spec and environment": {
+			resource: v1alpha1.Resource{
+				URL:            "https://example.com/a.yaml",
+				SHA256:         "aa",
+				Headers:        map[string]string{"Accept": "application/yaml"},
+				HeadersFromEnv: map[string]string{"Authorization": "KOGEN_TEST_TOKEN"},
+			},
+			options: v1alpha1.ResourceOptions{Retries: &zero},
+			expected: cache.Request{
+				URL:     "https://example.com/a.yaml",
+				SHA256:  "aa",
+				Headers: map[string]string{"Accept": "application/yaml", "Authorization": "Bearer secret"},
+				Timeout: time.Minute,
+			},
+		},
+		"unset environment variable": {
+			resource:    v1alpha1.Resource{URL: "https://example.com/a.yaml", HeadersFromEnv: map[string]string{"Authorization": "KOGEN_TEST_UNSET"}},
+			expectError: "environment variable KOGEN_TEST_UNSET for header Authorization of resource https://example.com/a.yaml is not set",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := &Generator{spec: v1alpha1.CogSpec{ResourceOptions: tc.options}, resourceTimeout: time.Minute}
+			request, err := g.resourceRequest(tc.resource)
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, request)
+		})
+	}
+}
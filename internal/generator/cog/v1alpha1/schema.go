@@ -0,0 +1,95 @@
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/token"
+	"github.com/amir-ahmad/kogen/internal/helm"
+)
+
+// valuesPositions maps the paths of values to where they were set in cue. Paths are keyed by
+// valuesKey.
+type valuesPositions map[string]token.Pos
+
+// newValuesPositions records the positions of v and every value within it.
+func newValuesPositions(v cue.Value) valuesPositions {
+	positions := valuesPositions{}
+	if !v.Exists() {
+		return positions
+	}
+
+	var walk func(v cue.Value, path []string)
+	walk = func(v cue.Value, path []string) {
+		if pos := v.Pos(); pos.IsValid() {
+			positions[valuesKey(path)] = pos
+		}
+
+		switch v.Kind() {
+		case cue.StructKind:
+			iter, err := v.Fields()
+			if err != nil {
+				return
+			}
+			for iter.Next() {
+				walk(iter.Value(), append(slices.Clone(path), iter.Selector().Unquoted()))
+			}
+		case cue.ListKind:
+			iter, err := v.List()
+			if err != nil {
+				return
+			}
+			for i := 0; iter.Next(); i++ {
+				walk(iter.Value(), append(slices.Clone(path), strconv.Itoa(i)))
+			}
+		}
+	}
+	walk(v, nil)
+
+	return positions
+}
+
+// valuesKey returns the key of a path in valuesPositions.
+func valuesKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// lookup returns the position of the value at path. Values that weren't set in cue, such as
+// those from values files, have no position.
+func (p valuesPositions) lookup(path []string) (token.Pos, bool) {
+	pos, ok := p[valuesKey(path)]
+	return pos, ok
+}
+
+// withValuesPositions adds the cue positions of the values to schema violations in err.
+func withValuesPositions(err error, positions valuesPositions, instanceDir string) error {
+	var schemaErr *helm.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("values don't match the chart schema:")
+	for _, v := range schemaErr.Violations {
+		sb.WriteString("\n  ")
+		if pos, ok := positions.lookup(v.Path); ok {
+			sb.WriteString(positionString(pos, instanceDir) + ": ")
+		}
+		fmt.Fprintf(&sb, "%s: at %s: %s", v.Chart, helm.ValuesPath(v.Path), v.Message) //nolint:errcheck
+	}
+	return errors.New(sb.String())
+}
+
+// positionString formats a position with its file relative to the instance.
+func positionString(pos token.Pos, instanceDir string) string {
+	file := pos.Filename()
+	if rel, err := filepath.Rel(instanceDir, file); err == nil && filepath.IsLocal(rel) {
+		file = rel
+	}
+	return fmt.Sprintf("%s:%d:%d", filepath.ToSlash(file), pos.Line(), pos.Column())
+}
@@ -0,0 +1,38 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/amir-ahmad/kogen/internal/helm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithValuesPositions(t *testing.T) {
+	v := cuecontext.New().CompileString(`values: {
+	image: tag: 1
+	ports: [80, "https"]
+}`, cue.Filename("/instance/kogen.cue"))
+	require.NoError(t, v.Err())
+	positions := newValuesPositions(v.LookupPath(cue.ParsePath("values")))
+
+	err := withValuesPositions(&helm.SchemaError{Violations: []helm.SchemaViolation{
+		{Chart: "hello", Path: []string{}, Message: "missing property 'name'"},
+		{Chart: "hello", Path: []string{"env"}, Message: "value must be one of 'dev', 'prod'"},
+		{Chart: "hello", Path: []string{"image", "tag"}, Message: "got number, want string"},
+		{Chart: "hello", Path: []string{"ports", "1"}, Message: "got string, want integer"},
+	}}, positions, "/instance")
+
+	assert.EqualError(t, err, `values don't match the chart schema:
+  kogen.cue:1:1: hello: at .: missing property 'name'
+  hello: at .env: value must be one of 'dev', 'prod'
+  kogen.cue:2:9: hello: at .image.tag: got number, want string
+  kogen.cue:3:14: hello: at .ports.1: got string, want integer`)
+}
+
+func TestWithValuesPositions_OtherErrors(t *testing.T) {
+	err := assert.AnError
+	assert.Equal(t, err, withValuesPositions(err, valuesPositions{}, "/instance"))
+}
@@ -1,12 +1,14 @@
 package generator
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"iter"
 	"sync"
 
 	"cuelang.org/go/cue"
+	"github.com/amir-ahmad/kogen/internal/cache"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -20,22 +22,90 @@ var (
 type InitGenerator = func(input GeneratorInput) (Generator, error)
 
 // All generators must implement this interface.
+//
+// Generate may be called concurrently with other generators. Cue values aren't safe for
+// concurrent use, so generators should read everything they need from their spec when they
+// are initialised.
 type Generator interface {
 	Generate(options Options) (iter.Seq2[Object, error], error)
 }
 
 // Generators return an iterator of Objects.
 type Object interface {
-	// GetName returns the name of the object.
+	// GetAPIVersion returns the apiVersion of the object.
+	GetAPIVersion() string
+	// GetKind returns the kind of the object.
 	GetKind() string
+	// GetNamespace returns the namespace of the object, or an empty string if it has none.
+	GetNamespace() string
+	// GetName returns the name of the object.
+	GetName() string
 	// Output writes the object to the provided writer in yaml format.
 	Output(w io.Writer) error
+	// MarshalJSON encodes the object as json.
+	json.Marshaler
 }
 
+// ObjectKey returns a key that uniquely identifies an object by its apiVersion, kind, namespace
+// and name.
+func ObjectKey(obj Object) string {
+	return fmt.Sprintf(
+		"%s|%s|%s|%s",
+		obj.GetAPIVersion(),
+		obj.GetKind(),
+		obj.GetNamespace(),
+		obj.GetName(),
+	)
+}
+
+// KubeVersioner is implemented by generators that target a specific Kubernetes version.
+type KubeVersioner interface {
+	// KubeVersion returns the Kubernetes version, or an empty string if it isn't set.
+	KubeVersion() string
+}
+
+// Artifact is a remote artifact that a generator downloads into the cache.
+type Artifact struct {
+	// Name describes the artifact, such as the url it is downloaded from.
+	Name string
+	// Cached is whether the artifact is already in the cache, and doesn't need to be
+	// revalidated under the cache policy.
+	Cached bool
+	// Fetch downloads the artifact into the cache if it isn't already there, and returns its
+	// digest in the form sha256:<hex>, or git:<commit> for checkouts of git sources.
+	Fetch func() (string, error)
+}
+
+// Fetcher is implemented by generators that download remote artifacts.
+type Fetcher interface {
+	// Artifacts returns every remote artifact the generator needs.
+	Artifacts(options Options) ([]Artifact, error)
+}
+
+// Annotations added to objects when Options.Provenance is set.
+const (
+	// AnnotationGenerator is the label of the generator that produced an object.
+	AnnotationGenerator = "kogen.internal/generator"
+	// AnnotationSource is where an object was read from within its generator, such as a helm
+	// chart template or a resource file.
+	AnnotationSource = "kogen.internal/source"
+)
+
 // Options for generators.
 type Options struct {
 	// CacheDir is the directory to use for downloading artifacts.
 	CacheDir string
+
+	// CachePolicy is when remote files in the cache are revalidated.
+	CachePolicy cache.Policy
+
+	// Provenance enables adding the AnnotationGenerator and AnnotationSource annotations to
+	// every object.
+	Provenance bool
+
+	// AllowPostRenderCommands allows helm post renderers to run commands. Generators fail when
+	// a post renderer has a command and this isn't set.
+	AllowPostRenderCommands bool
 }
 
 // GeneratorInput is the input to a generator.
@@ -43,8 +113,14 @@ type GeneratorInput struct {
 	metav1.TypeMeta `json:",inline"`
 	Spec            cue.Value
 
+	// Label is the field name of the generator under the kogen field.
+	Label string
+
 	// InstanceDir is the directory that the config was loaded from.
 	InstanceDir string
+
+	// ModuleRoot is the root directory of the cue module that the config was loaded from.
+	ModuleRoot string
 }
 
 // Register registers a generator for a specific GVK.
@@ -0,0 +1,147 @@
+package v1alpha1
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/amir-ahmad/kogen/api/v1alpha1"
+	"github.com/amir-ahmad/kogen/internal/cache"
+	"github.com/amir-ahmad/kogen/internal/generator"
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/amir-ahmad/kogen/internal/kustomize"
+	godigest "github.com/opencontainers/go-digest"
+	kustomize_types "sigs.k8s.io/kustomize/api/types"
+)
+
+// Generator implements generator.Generator.
+type Generator struct {
+	spec        v1alpha1.KustomizeSpec
+	label       string
+	instanceDir string
+	moduleRoot  string
+}
+
+// Compile time check to ensure Generator implements generator.Generator.
+var _ generator.Generator = (*Generator)(nil)
+
+// Compile time check to ensure Generator implements generator.Fetcher.
+var _ generator.Fetcher = (*Generator)(nil)
+
+func NewGenerator(input generator.GeneratorInput) (generator.Generator, error) {
+	var spec v1alpha1.KustomizeSpec
+	if err := input.Spec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("when decoding kustomize spec: %w", err)
+	}
+
+	if spec.Path == "" {
+		return nil, fmt.Errorf("kustomize spec requires a path")
+	}
+
+	return &Generator{
+		spec:        spec,
+		label:       input.Label,
+		instanceDir: input.InstanceDir,
+		moduleRoot:  input.ModuleRoot,
+	}, nil
+}
+
+// Generate implements generator.Generator.
+func (g *Generator) Generate(
+	options generator.Options,
+) (iter.Seq2[generator.Object, error], error) {
+	var manifests []byte
+	var err error
+	if kustomize.IsRemote(g.spec.Path) {
+		manifests, err = getRemoteKustomization(
+			g.spec.Path,
+			filepath.Join(options.CacheDir, "kustomize"),
+			options.CachePolicy,
+		)
+	} else {
+		manifests, err = kustomize.BuildDir(g.localPath(), g.moduleRoot)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("when building kustomization %s: %w", g.spec.Path, err)
+	}
+
+	if len(g.spec.Patches) > 0 {
+		kustomization := kustomize_types.Kustomization{Patches: g.spec.Patches}
+		manifests, err = kustomize.Build(manifests, kustomization, g.instanceDir, g.moduleRoot)
+		if err != nil {
+			return nil, fmt.Errorf("when patching kustomization %s: %w", g.spec.Path, err)
+		}
+	}
+
+	var annotations map[string]string
+	if options.Provenance {
+		annotations = map[string]string{
+			generator.AnnotationGenerator: g.label,
+			generator.AnnotationSource:    "kustomize:" + g.spec.Path,
+		}
+	}
+
+	st := store.NewObjectStore()
+	if err := st.AddYamlWithAnnotations(manifests, annotations); err != nil {
+		return nil, fmt.Errorf("when adding objects of kustomization %s to store: %w", g.spec.Path, err)
+	}
+
+	return st.GetIterator(), nil
+}
+
+// Artifacts implements generator.Fetcher.
+func (g *Generator) Artifacts(options generator.Options) ([]generator.Artifact, error) {
+	if !kustomize.IsRemote(g.spec.Path) {
+		return nil, nil
+	}
+
+	cacheDir := filepath.Join(options.CacheDir, "kustomize")
+	return []generator.Artifact{{
+		Name:   "kustomize:" + g.spec.Path,
+		Cached: options.CachePolicy.IsFresh(remoteCacheFile(g.spec.Path, cacheDir)),
+		Fetch: func() (string, error) {
+			manifests, err := getRemoteKustomization(g.spec.Path, cacheDir, options.CachePolicy)
+			if err != nil {
+				return "", err
+			}
+			return godigest.FromBytes(manifests).String(), nil
+		},
+	}}, nil
+}
+
+// localPath returns the path of a local kustomization, resolved relative to the cue instance.
+func (g *Generator) localPath() string {
+	if filepath.IsAbs(g.spec.Path) {
+		return g.spec.Path
+	}
+	return filepath.Join(g.instanceDir, g.spec.Path)
+}
+
+// remoteCacheFile returns the path the output of a remote kustomization is cached at.
+func remoteCacheFile(url string, cacheDir string) string {
+	return filepath.Join(cacheDir, fmt.Sprintf("%x.yaml", sha256.Sum256([]byte(url))))
+}
+
+// getRemoteKustomization builds a remote kustomization and caches its output, which is used
+// until the policy requires the kustomization to be built again.
+func getRemoteKustomization(url string, cacheDir string, policy cache.Policy) ([]byte, error) {
+	cacheFile := remoteCacheFile(url, cacheDir)
+	if policy.IsFresh(cacheFile) {
+		if data, err := os.ReadFile(cacheFile); err == nil {
+			cache.Touch(cacheFile)
+			return data, nil
+		}
+	}
+
+	manifests, err := kustomize.BuildRemote(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.WriteChecked(cacheFile, url, manifests); err != nil {
+		return nil, fmt.Errorf("when caching kustomization %s: %w", url, err)
+	}
+	return manifests, nil
+}
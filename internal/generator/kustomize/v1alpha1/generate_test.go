@@ -0,0 +1,106 @@
+package v1alpha1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/amir-ahmad/kogen/internal/cache"
+	"github.com/amir-ahmad/kogen/internal/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGenerator_MissingPath(t *testing.T) {
+	spec := cuecontext.New().CompileString(`patches: []`)
+	_, err := NewGenerator(generator.GeneratorInput{Spec: spec})
+	require.ErrorContains(t, err, "kustomize spec requires a path")
+}
+
+func TestGenerate_Local(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"base/kustomization.yaml": "resources: [configmap.yaml]\nnamePrefix: base-\n",
+		"base/configmap.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	spec := cuecontext.New().CompileString(`
+		path: "base"
+		patches: [{
+			target: kind: "ConfigMap"
+			patch: """
+				- op: add
+				  path: /data
+				  value: {mode: prod}
+				"""
+		}]
+	`)
+	gen, err := NewGenerator(generator.GeneratorInput{
+		Spec:        spec,
+		Label:       "app",
+		InstanceDir: dir,
+		ModuleRoot:  dir,
+	})
+	require.NoError(t, err)
+
+	it, err := gen.Generate(generator.Options{Provenance: true})
+	require.NoError(t, err)
+
+	var names []string
+	for object, err := range it {
+		require.NoError(t, err)
+		names = append(names, object.GetName())
+
+		jsonBytes, err := object.MarshalJSON()
+		require.NoError(t, err)
+		assert.Contains(t, string(jsonBytes), `"mode":"prod"`)
+		assert.Contains(t, string(jsonBytes), `"kogen.internal/source":"kustomize:base"`)
+	}
+	assert.Equal(t, []string{"base-settings"}, names)
+
+	artifacts, err := gen.(generator.Fetcher).Artifacts(generator.Options{})
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
+
+func TestArtifacts_Remote(t *testing.T) {
+	cacheDir := t.TempDir()
+	url := "https://github.com/example/repo//deploy?ref=v1"
+
+	spec := cuecontext.New().CompileString(`path: "` + url + `"`)
+	gen, err := NewGenerator(generator.GeneratorInput{Spec: spec})
+	require.NoError(t, err)
+
+	artifacts, err := gen.(generator.Fetcher).Artifacts(generator.Options{CacheDir: cacheDir})
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, "kustomize:"+url, artifacts[0].Name)
+	assert.False(t, artifacts[0].Cached)
+
+	// The cached output is used instead of downloading the kustomization.
+	cacheFile := remoteCacheFile(url, filepath.Join(cacheDir, "kustomize"))
+	require.NoError(t, os.MkdirAll(filepath.Dir(cacheFile), 0o755))
+	require.NoError(t, os.WriteFile(cacheFile, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cached\n"), 0o644))
+
+	artifacts, err = gen.(generator.Fetcher).Artifacts(generator.Options{CacheDir: cacheDir})
+	require.NoError(t, err)
+	assert.True(t, artifacts[0].Cached)
+
+	it, err := gen.Generate(generator.Options{CacheDir: cacheDir})
+	require.NoError(t, err)
+	for object, err := range it {
+		require.NoError(t, err)
+		assert.Equal(t, "cached", object.GetName())
+	}
+	// The output is built again when the policy requires it to be revalidated.
+	artifacts, err = gen.(generator.Fetcher).Artifacts(generator.Options{
+		CacheDir:    cacheDir,
+		CachePolicy: cache.Policy{Refresh: true},
+	})
+	require.NoError(t, err)
+	assert.False(t, artifacts[0].Cached)
+}
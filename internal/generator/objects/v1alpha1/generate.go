@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"io"
 	"iter"
+	"maps"
+	"path/filepath"
+	"slices"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/encoding/yaml"
@@ -12,13 +15,22 @@ import (
 
 // Generator implements generator.Generator.
 type Generator struct {
-	objects cue.Value
+	objects []Object
+	label   string
 }
 
 // Object implements generator.Object.
 type Object struct {
-	kind  string
-	value cue.Value
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+	value      cue.Value
+
+	// source is the position of the object in the cue config.
+	source string
+	// annotations are added to the object when it is encoded.
+	annotations map[string]string
 }
 
 // Compile time check to ensure Generator implements generator.Generator.
@@ -27,12 +39,24 @@ var _ generator.Generator = (*Generator)(nil)
 // Compile time check to ensure Object implements generator.Object.
 var _ generator.Object = (*Object)(nil)
 
+func (o Object) GetAPIVersion() string {
+	return o.apiVersion
+}
+
 func (o Object) GetKind() string {
 	return o.kind
 }
 
+func (o Object) GetNamespace() string {
+	return o.namespace
+}
+
+func (o Object) GetName() string {
+	return o.name
+}
+
 func (o Object) Output(w io.Writer) error {
-	yamlBytes, err := yaml.Encode(o.value)
+	yamlBytes, err := yaml.Encode(o.annotatedValue())
 	if err != nil {
 		return fmt.Errorf("failed to encode object to yaml: %w", err)
 	}
@@ -41,54 +65,126 @@ func (o Object) Output(w io.Writer) error {
 	return err
 }
 
+func (o Object) MarshalJSON() ([]byte, error) {
+	jsonBytes, err := o.annotatedValue().MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode object to json: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+// annotatedValue returns the value of the object with its annotations added. It is done when
+// encoding rather than in Generate, as objects are encoded sequentially.
+func (o Object) annotatedValue() cue.Value {
+	v := o.value
+	for _, key := range slices.Sorted(maps.Keys(o.annotations)) {
+		path := cue.MakePath(cue.Str("metadata"), cue.Str("annotations"), cue.Str(key))
+		v = v.FillPath(path, o.annotations[key])
+	}
+	return v
+}
+
 func NewGenerator(input generator.GeneratorInput) (generator.Generator, error) {
 	objects := input.Spec.LookupPath(cue.MakePath(cue.Str("objects")))
 	if err := objects.Err(); err != nil {
 		return nil, fmt.Errorf("failed to lookup objects: %w", err)
 	}
 
+	// Objects are read up front as cue values aren't safe for concurrent use, and Generate can
+	// run concurrently with other generators.
+	objectList, err := readObjects(objects, input.InstanceDir)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Generator{
-		objects: objects,
+		objects: objectList,
+		label:   input.Label,
 	}, nil
 }
 
-// Generate implements generator.Generator.
-func (g *Generator) Generate(
-	options generator.Options,
-) (iter.Seq2[generator.Object, error], error) {
-	iter, err := g.objects.List()
+// readObjects reads each object in a cue list. Source positions are made relative to
+// instanceDir.
+func readObjects(objects cue.Value, instanceDir string) ([]Object, error) {
+	iter, err := objects.List()
 	if err != nil {
 		return nil, fmt.Errorf("failed to iterate objects: %w", err)
 	}
 
-	return func(yield func(generator.Object, error) bool) {
-		for iter.Next() {
-			v := iter.Value()
-			if err := v.Err(); err != nil {
-				yield(Object{}, fmt.Errorf("error getting cue value for object: %w", err))
-				return
-			}
+	var objectList []Object
+	for iter.Next() {
+		v := iter.Value()
+		if err := v.Err(); err != nil {
+			return nil, fmt.Errorf("error getting cue value for object: %w", err)
+		}
 
-			kindVal := v.LookupPath(cue.MakePath(cue.Str("kind")))
-			if err := v.Err(); err != nil {
-				yield(Object{}, fmt.Errorf("failed to get kind for object: %w", err))
-				return
-			}
+		kindVal := v.LookupPath(cue.MakePath(cue.Str("kind")))
+		if err := v.Err(); err != nil {
+			return nil, fmt.Errorf("failed to get kind for object: %w", err)
+		}
 
-			kind, err := kindVal.String()
-			if err != nil {
-				yield(Object{}, fmt.Errorf("when getting kind as string: %w", err))
-				return
-			}
+		kind, err := kindVal.String()
+		if err != nil {
+			return nil, fmt.Errorf("when getting kind as string: %w", err)
+		}
 
-			object := Object{
-				kind:  kind,
-				value: v,
-			}
+		objectList = append(objectList, Object{
+			apiVersion: lookupString(v, "apiVersion"),
+			kind:       kind,
+			namespace:  lookupString(v, "metadata", "namespace"),
+			name:       lookupString(v, "metadata", "name"),
+			value:      v,
+			source:     objectSource(v, instanceDir),
+		})
+	}
 
+	return objectList, nil
+}
+
+// Generate implements generator.Generator.
+func (g *Generator) Generate(
+	options generator.Options,
+) (iter.Seq2[generator.Object, error], error) {
+	return func(yield func(generator.Object, error) bool) {
+		for _, object := range g.objects {
+			if options.Provenance {
+				object.annotations = map[string]string{
+					generator.AnnotationGenerator: g.label,
+					generator.AnnotationSource:    object.source,
+				}
+			}
 			if !yield(object, nil) {
 				return
 			}
 		}
 	}, nil
 }
+
+// objectSource returns the position of an object in the cue config, in the form
+// cue:<file>:<line>.
+func objectSource(v cue.Value, instanceDir string) string {
+	pos := v.Pos()
+	if !pos.IsValid() {
+		return "cue"
+	}
+
+	file := pos.Filename()
+	if rel, err := filepath.Rel(instanceDir, file); err == nil && filepath.IsLocal(rel) {
+		file = rel
+	}
+	return fmt.Sprintf("cue:%s:%d", filepath.ToSlash(file), pos.Line())
+}
+
+// lookupString returns the string at path in v, or an empty string if it doesn't exist.
+func lookupString(v cue.Value, path ...string) string {
+	selectors := make([]cue.Selector, 0, len(path))
+	for _, p := range path {
+		selectors = append(selectors, cue.Str(p))
+	}
+
+	s, err := v.LookupPath(cue.MakePath(selectors...)).String()
+	if err != nil {
+		return ""
+	}
+	return s
+}
@@ -25,6 +25,19 @@ func TestOutput_EncodeError(t *testing.T) {
 	require.ErrorContains(t, err, "failed to encode object to yaml")
 }
 
+func TestMarshalJSON_EncodeError(t *testing.T) {
+	ctx := cuecontext.New()
+	val := ctx.CompileString("_")
+
+	obj := Object{
+		kind:  "ConfigMap",
+		value: val,
+	}
+
+	_, err := obj.MarshalJSON()
+	require.ErrorContains(t, err, "failed to encode object to json")
+}
+
 func TestGenerate_ExitEarly(t *testing.T) {
 	ctx := cuecontext.New()
 	input := `
@@ -44,9 +57,8 @@ func TestGenerate_ExitEarly(t *testing.T) {
 	val := ctx.CompileString(input)
 	require.NoError(t, val.Err(), "failed to compile cue")
 
-	gen := &Generator{
-		objects: val.LookupPath(cue.MakePath(cue.Str("objects"))),
-	}
+	gen, err := NewGenerator(generator.GeneratorInput{Spec: val})
+	require.NoError(t, err, "failed to create generator")
 
 	iter, err := gen.Generate(generator.Options{})
 	require.NoError(t, err, "failed to generate objects")
@@ -63,3 +75,38 @@ func TestGenerate_ExitEarly(t *testing.T) {
 
 	require.Equal(t, 1, count)
 }
+
+func TestGenerate_Provenance(t *testing.T) {
+	ctx := cuecontext.New()
+	input := `objects: [{
+	apiVersion: "v1"
+	kind: "ConfigMap"
+	metadata: name: "test"
+	metadata: annotations: existing: "value"
+}]
+`
+	val := ctx.CompileString(input, cue.Filename("/config/kogen.cue"))
+	require.NoError(t, val.Err(), "failed to compile cue")
+
+	gen, err := NewGenerator(generator.GeneratorInput{Spec: val, Label: "app", InstanceDir: "/config"})
+	require.NoError(t, err, "failed to create generator")
+
+	iter, err := gen.Generate(generator.Options{Provenance: true})
+	require.NoError(t, err, "failed to generate objects")
+
+	var buf bytes.Buffer
+	for obj, err := range iter {
+		require.NoError(t, err)
+		require.NoError(t, obj.Output(&buf))
+	}
+
+	require.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  annotations:
+    existing: value
+    kogen.internal/generator: app
+    kogen.internal/source: cue:kogen.cue:1
+`, buf.String())
+}
@@ -0,0 +1,145 @@
+package git
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// run runs git with args in dir and returns its trimmed stdout.
+func run(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf(
+			"git %s: %w: %s",
+			strings.Join(args, " "),
+			err,
+			strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Export writes the tree of the repository containing the working directory at revision rev
+// into destDir. It returns the directory within destDir that corresponds to the working
+// directory, so relative paths can be resolved the same way against the exported tree.
+func Export(rev string, destDir string) (string, error) {
+	topLevel, err := run("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	prefix, err := run("", "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := run(topLevel, "rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("when resolving revision %s: %w", rev, err)
+	}
+
+	if err := archive(topLevel, commit, destDir); err != nil {
+		return "", fmt.Errorf("when exporting revision %s: %w", rev, err)
+	}
+
+	return filepath.Join(destDir, filepath.FromSlash(prefix)), nil
+}
+
+// archive writes the tree of commit in the repository at dir into destDir.
+func archive(dir, commit, destDir string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "archive", "--format=tar", commit)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("when running git archive: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("when running git archive: %w", err)
+	}
+
+	if err := extractTar(stdout, destDir); err != nil {
+		_ = cmd.Wait()
+		return fmt.Errorf("when extracting commit %s: %w", commit, err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf(
+			"git archive %s: %w: %s",
+			commit,
+			err,
+			strings.TrimSpace(stderr.String()),
+		)
+	}
+	return nil
+}
+
+// extractTar extracts a tar stream produced by git archive into destDir.
+func extractTar(r io.Reader, destDir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+		target := filepath.Join(destDir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, header.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,272 @@
+package git
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/amir-ahmad/kogen/internal/cache"
+)
+
+// SourcePrefix is the prefix of paths within a git repository, such as
+// git::https://github.com/org/repo//charts/app?ref=v1.2.3.
+const SourcePrefix = "git::"
+
+// Source is a path within a git repository at a ref.
+type Source struct {
+	// URL of the repository, in any form git can fetch from.
+	URL string
+	// Path within the repository, or empty for its root.
+	Path string
+	// Ref is the branch, tag or full commit to check out. Defaults to the remote HEAD.
+	Ref string
+}
+
+// IsSource returns whether s is a path within a git repository.
+func IsSource(s string) bool {
+	return strings.HasPrefix(s, SourcePrefix)
+}
+
+// ParseSource parses a source of the form git::<url>//<path>?ref=<ref>, where the path and
+// ref are optional.
+func ParseSource(s string) (Source, error) {
+	if !IsSource(s) {
+		return Source{}, fmt.Errorf("git source %s must start with %s", s, SourcePrefix)
+	}
+	rest := strings.TrimPrefix(s, SourcePrefix)
+
+	var source Source
+	if i := strings.LastIndex(rest, "?"); i >= 0 {
+		query, err := url.ParseQuery(rest[i+1:])
+		if err != nil {
+			return Source{}, fmt.Errorf("when parsing query of git source %s: %w", s, err)
+		}
+		for key := range query {
+			if key != "ref" {
+				return Source{}, fmt.Errorf("git source %s has unknown query parameter %s", s, key)
+			}
+		}
+		source.Ref = query.Get("ref")
+		rest = rest[:i]
+	}
+
+	// The path is separated by the first // after the scheme.
+	offset := 0
+	if i := strings.Index(rest, "://"); i >= 0 {
+		offset = i + len("://")
+	}
+	source.URL = rest
+	if i := strings.Index(rest[offset:], "//"); i >= 0 {
+		source.URL = rest[:offset+i]
+		source.Path = strings.Trim(rest[offset+i+2:], "/")
+	}
+
+	if source.URL == "" {
+		return Source{}, fmt.Errorf("git source %s has no repository url", s)
+	}
+	// Values starting with - would be read by git as options.
+	if strings.HasPrefix(source.URL, "-") {
+		return Source{}, fmt.Errorf("repository url of git source %s can't start with -", s)
+	}
+	if strings.HasPrefix(source.Ref, "-") {
+		return Source{}, fmt.Errorf("ref of git source %s can't start with -", s)
+	}
+	if source.Path != "" && !filepath.IsLocal(filepath.FromSlash(source.Path)) {
+		return Source{}, fmt.Errorf("path of git source %s is not within the repository", s)
+	}
+	source.Path = path.Clean(source.Path)
+	if source.Path == "." {
+		source.Path = ""
+	}
+
+	return source, nil
+}
+
+// commitPattern matches full sha1 and sha256 commit ids.
+var commitPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// isPinned returns whether the ref of the source is a full commit id, which always refers to
+// the same commit. Other refs, such as branches, can move.
+func (s Source) isPinned() bool {
+	return commitPattern.MatchString(s.Ref)
+}
+
+// refFile returns the file that records the commit the ref of the source resolved to.
+func (s Source) refFile(cacheDir string) string {
+	hash := sha256.Sum256([]byte(s.URL + "\x00" + s.Ref))
+	return filepath.Join(cacheDir, "refs", fmt.Sprintf("%x", hash))
+}
+
+// cachedCommit returns the commit the ref of the source was checked out at, if it's in the
+// cache and the ref doesn't need to be resolved again under the policy.
+func (s Source) cachedCommit(cacheDir string, policy cache.Policy) (string, bool) {
+	refFile := s.refFile(cacheDir)
+	if !s.isPinned() && !policy.IsFresh(refFile) {
+		return "", false
+	}
+
+	data, err := os.ReadFile(refFile)
+	if err != nil {
+		return "", false
+	}
+	commit := strings.TrimSpace(string(data))
+	if _, err := os.Stat(filepath.Join(cacheDir, commit)); err != nil {
+		return "", false
+	}
+	return commit, true
+}
+
+// IsCached returns whether the ref of the source is already checked out in cacheDir, and
+// doesn't need to be resolved again under the policy.
+func (s Source) IsCached(cacheDir string, policy cache.Policy) bool {
+	_, ok := s.cachedCommit(cacheDir, policy)
+	return ok
+}
+
+// Checkout checks out the ref of the source into cacheDir, unless it already is, and returns
+// the path within the checkout along with the commit it's at. Checkouts are shallow and keyed
+// by commit, so refs resolving to the same commit share one checkout. The commit a ref
+// resolved to is recorded, so that later builds don't need to fetch the ref again. Full
+// commit ids are recorded for good, while other refs are resolved again when the policy
+// requires it, and only fetched when they've moved to a commit that isn't checked out.
+func (s Source) Checkout(cacheDir string, policy cache.Policy) (string, string, error) {
+	commit, ok := s.cachedCommit(cacheDir, policy)
+	if ok {
+		cache.Touch(s.refFile(cacheDir))
+		cache.Touch(filepath.Join(cacheDir, commit))
+	} else {
+		var err error
+		commit, err = s.update(cacheDir)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return filepath.Join(cacheDir, commit, filepath.FromSlash(s.Path)), commit, nil
+}
+
+// update resolves the ref of the source in the remote repository, and fetches it unless its
+// commit is already checked out in cacheDir. It returns the commit.
+func (s Source) update(cacheDir string) (string, error) {
+	if !s.isPinned() {
+		// Refs that can't be resolved here are left to fetch, which reports why.
+		if commit, err := s.remoteCommit(); err == nil && commit != "" {
+			checkoutDir := filepath.Join(cacheDir, commit)
+			if _, err := os.Stat(checkoutDir); err == nil {
+				if err := s.recordCommit(cacheDir, commit); err != nil {
+					return "", err
+				}
+				cache.Touch(checkoutDir)
+				return commit, nil
+			}
+		}
+	}
+	return s.fetch(cacheDir)
+}
+
+// remoteCommit returns the commit the ref of the source points to in the remote repository, or
+// an empty string when it isn't a ref the repository lists, such as an abbreviated commit.
+func (s Source) remoteCommit() (string, error) {
+	ref := s.ref()
+	output, err := run("", "ls-remote", "--", s.URL, ref, ref+"^{}")
+	if err != nil {
+		return "", fmt.Errorf("when resolving %s of %s: %w", ref, s.URL, err)
+	}
+
+	refs := map[string]string{}
+	for line := range strings.SplitSeq(output, "\n") {
+		if commit, name, ok := strings.Cut(line, "\t"); ok {
+			refs[name] = commit
+		}
+	}
+
+	// Names are resolved in the same order as git does, preferring the commit an annotated tag
+	// points to over the tag itself.
+	for _, format := range []string{"%s", "refs/%s", "refs/tags/%s", "refs/heads/%s"} {
+		name := fmt.Sprintf(format, ref)
+		if commit, ok := refs[name+"^{}"]; ok {
+			return commit, nil
+		}
+		if commit, ok := refs[name]; ok {
+			return commit, nil
+		}
+	}
+	return "", nil
+}
+
+// ref returns the ref of the source to fetch.
+func (s Source) ref() string {
+	if s.Ref == "" {
+		return "HEAD"
+	}
+	return s.Ref
+}
+
+// recordCommit records the commit the ref of the source resolved to.
+func (s Source) recordCommit(cacheDir, commit string) error {
+	source := s.URL
+	if s.Ref != "" {
+		source += "?ref=" + s.Ref
+	}
+	if err := cache.WriteChecked(s.refFile(cacheDir), source, []byte(commit+"\n")); err != nil {
+		return fmt.Errorf("when recording commit of %s: %w", s.URL, err)
+	}
+	return nil
+}
+
+// fetch shallowly fetches the ref of the source, extracts its tree into cacheDir and returns
+// its commit.
+func (s Source) fetch(cacheDir string) (string, error) {
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create git cache directory: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(cacheDir, ".fetch-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir) //nolint:errcheck
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	if _, err := run(tmpDir, "init", "--quiet", "--bare", repoDir); err != nil {
+		return "", err
+	}
+
+	ref := s.ref()
+	if _, err := run(repoDir, "fetch", "--quiet", "--depth", "1", "--", s.URL, ref); err != nil {
+		return "", fmt.Errorf("when fetching %s of %s: %w", ref, s.URL, err)
+	}
+
+	commit, err := run(repoDir, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", err
+	}
+
+	checkoutDir := filepath.Join(cacheDir, commit)
+	if _, err := os.Stat(checkoutDir); errors.Is(err, fs.ErrNotExist) {
+		treeDir := filepath.Join(tmpDir, "tree")
+		if err := os.Mkdir(treeDir, 0o755); err != nil {
+			return "", err
+		}
+		if err := archive(repoDir, commit, treeDir); err != nil {
+			return "", err
+		}
+		// Another build may have checked out the same commit in the meantime.
+		if err := os.Rename(treeDir, checkoutDir); err != nil {
+			if _, statErr := os.Stat(checkoutDir); statErr != nil {
+				return "", fmt.Errorf("failed to move checkout of %s into the cache: %w", s.URL, err)
+			}
+		}
+	}
+
+	if err := s.recordCommit(cacheDir, commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
@@ -0,0 +1,175 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amir-ahmad/kogen/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := map[string]struct {
+		source      string
+		expected    Source
+		expectError string
+	}{
+		"https with path and ref": {
+			source:   "git::https://github.com/org/repo//charts/app?ref=v1.2.3",
+			expected: Source{URL: "https://github.com/org/repo", Path: "charts/app", Ref: "v1.2.3"},
+		},
+		"file url without path": {
+			source:   "git::file:///tmp/repo?ref=main",
+			expected: Source{URL: "file:///tmp/repo", Ref: "main"},
+		},
+		"scp-like url without ref": {
+			source:   "git::git@github.com:org/repo.git//deploy/",
+			expected: Source{URL: "git@github.com:org/repo.git", Path: "deploy"},
+		},
+		"missing prefix": {
+			source:      "https://github.com/org/repo//deploy",
+			expectError: "must start with git::",
+		},
+		"path outside the repository": {
+			source:      "git::https://github.com/org/repo//../other",
+			expectError: "is not within the repository",
+		},
+		"unknown query parameter": {
+			source:      "git::https://github.com/org/repo//deploy?depth=1",
+			expectError: "unknown query parameter depth",
+		},
+		"missing url": {
+			source:      "git::?ref=v1",
+			expectError: "has no repository url",
+		},
+		"url that looks like an option": {
+			source:      "git::--upload-pack=touch /tmp/pwned//deploy",
+			expectError: "repository url of git source git::--upload-pack=touch /tmp/pwned//deploy can't start with -",
+		},
+		"ref that looks like an option": {
+			source:      "git::https://github.com/org/repo//deploy?ref=--upload-pack=touch%20/tmp/pwned",
+			expectError: "can't start with -",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			source, err := ParseSource(tc.source)
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, source)
+		})
+	}
+}
+
+func TestSourceCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repoDir := t.TempDir()
+	gitRun := func(args ...string) string {
+		out, err := run(repoDir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		require.NoError(t, err)
+		return out
+	}
+	gitRun("init", "--quiet")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "deploy"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "deploy", "app.yaml"), []byte("v1"), 0o644))
+	gitRun("add", ".")
+	gitRun("commit", "--quiet", "-m", "v1")
+	gitRun("tag", "v1")
+	commit := gitRun("rev-parse", "HEAD")
+
+	cacheDir := t.TempDir()
+	source := Source{URL: "file://" + repoDir, Path: "deploy", Ref: "v1"}
+	assert.False(t, source.IsCached(cacheDir, cache.Policy{}))
+
+	path, resolved, err := source.Checkout(cacheDir, cache.Policy{})
+	require.NoError(t, err)
+	assert.Equal(t, commit, resolved)
+	assert.Equal(t, filepath.Join(cacheDir, commit, "deploy"), path)
+	data, err := os.ReadFile(filepath.Join(path, "app.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+	assert.True(t, source.IsCached(cacheDir, cache.Policy{}))
+
+	// The recorded commit is reused without fetching again.
+	require.NoError(t, os.RemoveAll(repoDir))
+	path, resolved, err = source.Checkout(cacheDir, cache.Policy{})
+	require.NoError(t, err)
+	assert.Equal(t, commit, resolved)
+	assert.Equal(t, filepath.Join(cacheDir, commit, "deploy"), path)
+
+	_, _, err = Source{URL: "file://" + repoDir, Ref: "v2"}.Checkout(cacheDir, cache.Policy{})
+	require.ErrorContains(t, err, "when fetching v2 of file://")
+}
+
+func TestSourceCheckout_Policy(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repoDir := t.TempDir()
+	gitRun := func(args ...string) string {
+		out, err := run(repoDir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		require.NoError(t, err)
+		return out
+	}
+	commit := func(content string) string {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, "app.yaml"), []byte(content), 0o644))
+		gitRun("add", ".")
+		gitRun("commit", "--quiet", "-m", content)
+		return gitRun("rev-parse", "HEAD")
+	}
+	gitRun("init", "--quiet", "--initial-branch", "main")
+	first := commit("v1")
+
+	cacheDir := t.TempDir()
+	branch := Source{URL: "file://" + repoDir, Ref: "main"}
+	_, resolved, err := branch.Checkout(cacheDir, cache.Policy{})
+	require.NoError(t, err)
+	assert.Equal(t, first, resolved)
+	refHash := sha256.Sum256([]byte(branch.URL + "\x00" + branch.Ref))
+	assert.FileExists(t, filepath.Join(cacheDir, "refs", hex.EncodeToString(refHash[:])))
+
+	// A branch keeps the commit it resolved to until the policy requires it to be resolved again.
+	second := commit("v2")
+	_, resolved, err = branch.Checkout(cacheDir, cache.Policy{TTL: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, first, resolved)
+
+	refresh := cache.Policy{Refresh: true}
+	assert.False(t, branch.IsCached(cacheDir, refresh))
+	path, resolved, err := branch.Checkout(cacheDir, refresh)
+	require.NoError(t, err)
+	assert.Equal(t, second, resolved)
+	data, err := os.ReadFile(filepath.Join(path, "app.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+
+	// Moving back to a commit that's checked out only resolves the ref.
+	gitRun("reset", "--quiet", "--hard", first)
+	_, resolved, err = branch.Checkout(cacheDir, refresh)
+	require.NoError(t, err)
+	assert.Equal(t, first, resolved)
+
+	// Full commits never move, so they're never resolved again.
+	pinned := Source{URL: "file://" + repoDir, Ref: second}
+	_, _, err = pinned.Checkout(cacheDir, cache.Policy{})
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(repoDir))
+	assert.True(t, pinned.IsCached(cacheDir, refresh))
+	_, resolved, err = pinned.Checkout(cacheDir, refresh)
+	require.NoError(t, err)
+	assert.Equal(t, second, resolved)
+}
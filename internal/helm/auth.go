@@ -0,0 +1,317 @@
+package helm
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// Auth holds the credentials and TLS settings used to access a chart repository or registry.
+type Auth struct {
+	// Username and Password are used for basic authentication.
+	Username string
+	Password string
+
+	// Token is sent as a bearer token.
+	Token string
+
+	// CAFile is a file of PEM encoded certificates to verify the server with.
+	CAFile string
+
+	// CertFile and KeyFile are a PEM encoded client certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// InsecureSkipTLSVerify disables verifying the server certificate.
+	InsecureSkipTLSVerify bool
+}
+
+// hasCredentials returns whether a username or token is set.
+func (a Auth) hasCredentials() bool {
+	return a.Username != "" || a.Token != ""
+}
+
+// merge fills the fields of a that aren't set from other. Credentials are only taken from
+// other when a has none, so that a username from one source is never paired with a password
+// from another.
+func (a Auth) merge(other Auth) Auth {
+	if !a.hasCredentials() {
+		a.Username = other.Username
+		a.Password = other.Password
+		a.Token = other.Token
+	}
+	if a.CAFile == "" {
+		a.CAFile = other.CAFile
+	}
+	if a.CertFile == "" && a.KeyFile == "" {
+		a.CertFile = other.CertFile
+		a.KeyFile = other.KeyFile
+	}
+	a.InsecureSkipTLSVerify = a.InsecureSkipTLSVerify || other.InsecureSkipTLSVerify
+	return a
+}
+
+// envPrefixes are the prefixes of environment variables that auth is read from. Argo CD
+// prefixes the environment variables of config management plugins with ARGOCD_ENV_.
+var envPrefixes = []string{"KOGEN_HELM_AUTH_", "ARGOCD_ENV_KOGEN_HELM_AUTH_"}
+
+// envAuth reads auth for a repository from environment variables named after its host, such as
+// KOGEN_HELM_AUTH_CHARTS_EXAMPLE_COM_USERNAME for charts.example.com.
+func envAuth(repository string) Auth {
+	host := repositoryHost(repository)
+	if host == "" {
+		return Auth{}
+	}
+
+	key := strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(host))
+
+	lookup := func(name string) string {
+		for _, prefix := range envPrefixes {
+			if v := os.Getenv(prefix + key + "_" + name); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	return Auth{
+		Username:              lookup("USERNAME"),
+		Password:              lookup("PASSWORD"),
+		Token:                 lookup("TOKEN"),
+		CAFile:                lookup("CA_FILE"),
+		CertFile:              lookup("CERT_FILE"),
+		KeyFile:               lookup("KEY_FILE"),
+		InsecureSkipTLSVerify: lookup("INSECURE_SKIP_TLS_VERIFY") == "true",
+	}
+}
+
+// repositoriesAuth reads auth for a repository from helm's repositories.yaml, which is where
+// `helm repo add` stores credentials.
+func repositoriesAuth(repository string) (Auth, error) {
+	path := cli.New().RepositoryConfig
+	f, err := repo.LoadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Auth{}, nil
+	}
+	if err != nil {
+		return Auth{}, fmt.Errorf("when reading helm repositories file %s: %w", path, err)
+	}
+
+	for _, entry := range f.Repositories {
+		if strings.TrimSuffix(entry.URL, "/") != strings.TrimSuffix(repository, "/") {
+			continue
+		}
+		return Auth{
+			Username:              entry.Username,
+			Password:              entry.Password,
+			CAFile:                entry.CAFile,
+			CertFile:              entry.CertFile,
+			KeyFile:               entry.KeyFile,
+			InsecureSkipTLSVerify: entry.InsecureSkipTLSverify,
+		}, nil
+	}
+
+	return Auth{}, nil
+}
+
+// dockerConfigPath returns the path of the docker config file, which holds registry
+// credentials from `docker login`.
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// resolveAuth returns the auth to access the chart's repository with. Auth set on the chart
+// takes precedence, followed by environment variables, then helm's repositories.yaml. Registry
+// credentials for OCI charts otherwise come from the docker config file.
+func (c Chart) resolveAuth() (Auth, error) {
+	auth := c.Auth.merge(envAuth(c.Repository))
+
+	if c.GetChartType() == ChartTypeHTTP {
+		fileAuth, err := repositoriesAuth(c.Repository)
+		if err != nil {
+			return Auth{}, err
+		}
+		auth = auth.merge(fileAuth)
+	}
+
+	return auth, nil
+}
+
+// repositoryHost returns the host, including any port, of a repository URL.
+func repositoryHost(repository string) string {
+	u, err := url.Parse(repository)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// httpClient returns a client that uses the TLS settings of auth, and sends its token to host.
+func (a Auth) httpClient(host string) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: a.InsecureSkipTLSVerify, //nolint:gosec
+	}
+
+	if a.CAFile != "" {
+		caCert, err := os.ReadFile(a.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("when reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", a.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if a.CertFile != "" || a.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("when loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	var rt http.RoundTripper = transport
+	if a.Token != "" {
+		rt = bearerTransport{base: transport, host: host, token: a.Token}
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+// bearerTransport adds a bearer token to requests to host.
+type bearerTransport struct {
+	base  http.RoundTripper
+	host  string
+	token string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == t.host && req.Header.Get("Authorization") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// httpGetter implements getter.Getter for chart repositories. Credentials are only sent to
+// the repository host, so charts hosted elsewhere don't receive them.
+type httpGetter struct {
+	client *http.Client
+	host   string
+	auth   Auth
+}
+
+// Compile time check to ensure httpGetter implements getter.Getter.
+var _ getter.Getter = (*httpGetter)(nil)
+
+// newHTTPGetter creates a getter for a chart repository.
+func newHTTPGetter(repository string, auth Auth) (*httpGetter, error) {
+	host := repositoryHost(repository)
+	client, err := auth.httpClient(host)
+	if err != nil {
+		return nil, err
+	}
+	return &httpGetter{client: client, host: host, auth: auth}, nil
+}
+
+// Get implements getter.Getter.
+func (g *httpGetter) Get(url string, _ ...getter.Option) (*bytes.Buffer, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if g.auth.Username != "" && req.URL.Host == g.host {
+		req.SetBasicAuth(g.auth.Username, g.auth.Password)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, resp.Body); err != nil {
+		return nil, fmt.Errorf("when reading %s: %w", url, err)
+	}
+	return buf, nil
+}
+
+// providers returns getter providers that fetch from chart repositories with the getter.
+func (g *httpGetter) providers() getter.Providers {
+	return getter.Providers{{
+		Schemes: []string{"http", "https"},
+		New: func(...getter.Option) (getter.Getter, error) {
+			return g, nil
+		},
+	}}
+}
+
+// newRegistryClient creates a client for the chart's OCI registry.
+func (c Chart) newRegistryClient(auth Auth) (*registry.Client, error) {
+	host := repositoryHost(c.Repository)
+	httpClient, err := auth.httpClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []registry.ClientOption{registry.ClientOptHTTPClient(httpClient)}
+	switch {
+	case auth.Username != "":
+		opts = append(opts, registry.ClientOptBasicAuth(auth.Username, auth.Password))
+	case auth.Token == "":
+		// The docker config is used when it exists, otherwise helm's own registry config.
+		credentialsFile := cli.New().RegistryConfig
+		if path := dockerConfigPath(); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				credentialsFile = path
+			}
+		}
+		opts = append(opts, registry.ClientOptCredentialsFile(credentialsFile))
+	}
+
+	client, err := registry.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+	return client, nil
+}
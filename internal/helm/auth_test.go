@@ -0,0 +1,160 @@
+package helm
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvAuth(t *testing.T) {
+	t.Setenv("KOGEN_HELM_AUTH_CHARTS_EXAMPLE_COM_8443_USERNAME", "user")
+	t.Setenv("ARGOCD_ENV_KOGEN_HELM_AUTH_CHARTS_EXAMPLE_COM_8443_PASSWORD", "pass")
+	t.Setenv("KOGEN_HELM_AUTH_CHARTS_EXAMPLE_COM_8443_INSECURE_SKIP_TLS_VERIFY", "true")
+	t.Setenv("KOGEN_HELM_AUTH_REGISTRY_EXAMPLE_COM_TOKEN", "token")
+
+	assert.Equal(t,
+		Auth{Username: "user", Password: "pass", InsecureSkipTLSVerify: true},
+		envAuth("https://charts.example.com:8443/stable"),
+	)
+	assert.Equal(t, Auth{Token: "token"}, envAuth("oci://registry.example.com/charts/app"))
+	assert.Equal(t, Auth{}, envAuth("https://other.example.com"))
+}
+
+func TestMerge(t *testing.T) {
+	tests := map[string]struct {
+		auth     Auth
+		other    Auth
+		expected Auth
+	}{
+		"credentials aren't mixed between sources": {
+			auth:     Auth{Username: "user"},
+			other:    Auth{Username: "other", Password: "pass"},
+			expected: Auth{Username: "user"},
+		},
+		"credentials are taken when missing": {
+			auth:     Auth{CAFile: "ca.pem"},
+			other:    Auth{Token: "token", CAFile: "other.pem"},
+			expected: Auth{Token: "token", CAFile: "ca.pem"},
+		},
+		"client certificate is taken as a pair": {
+			auth:     Auth{KeyFile: "key.pem"},
+			other:    Auth{CertFile: "other-cert.pem", KeyFile: "other-key.pem"},
+			expected: Auth{KeyFile: "key.pem"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.auth.merge(tc.other))
+		})
+	}
+}
+
+func TestResolveAuth_RepositoriesFile(t *testing.T) {
+	repositoriesFile := filepath.Join(t.TempDir(), "repositories.yaml")
+	require.NoError(t, os.WriteFile(repositoriesFile, []byte(`apiVersion: ""
+generated: "0001-01-01T00:00:00Z"
+repositories:
+- name: private
+  url: https://charts.example.com/private/
+  username: file-user
+  password: file-pass
+  caFile: /etc/ca.pem
+`), 0o644))
+	t.Setenv("HELM_REPOSITORY_CONFIG", repositoriesFile)
+
+	chart := Chart{Repository: "https://charts.example.com/private"}
+	auth, err := chart.resolveAuth()
+	require.NoError(t, err)
+	assert.Equal(t, Auth{Username: "file-user", Password: "file-pass", CAFile: "/etc/ca.pem"}, auth)
+
+	// Auth set on the chart takes precedence.
+	chart.Auth = Auth{Token: "token"}
+	auth, err = chart.resolveAuth()
+	require.NoError(t, err)
+	assert.Equal(t, Auth{Token: "token", CAFile: "/etc/ca.pem"}, auth)
+}
+
+func TestDownloadChart_Auth(t *testing.T) {
+	// Helm config files on the machine running the tests mustn't be used.
+	t.Setenv("HELM_REPOSITORY_CONFIG", filepath.Join(t.TempDir(), "repositories.yaml"))
+
+	requireBasicAuth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	requireToken := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	tlsServer := serveTestRepository(t, httptest.NewTLSServer, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: tlsServer.Certificate().Raw,
+	}), 0o644))
+
+	tests := map[string]struct {
+		repository  string
+		auth        Auth
+		expectError string
+	}{
+		"basic auth": {
+			repository: serveTestRepository(t, httptest.NewServer, requireBasicAuth).URL,
+			auth:       Auth{Username: "user", Password: "pass"},
+		},
+		"wrong password": {
+			repository:  serveTestRepository(t, httptest.NewServer, requireBasicAuth).URL,
+			auth:        Auth{Username: "user", Password: "wrong"},
+			expectError: "401 Unauthorized",
+		},
+		"bearer token": {
+			repository: serveTestRepository(t, httptest.NewServer, requireToken).URL,
+			auth:       Auth{Token: "token"},
+		},
+		"custom CA": {
+			repository: tlsServer.URL,
+			auth:       Auth{CAFile: caFile},
+		},
+		"unknown CA": {
+			repository:  tlsServer.URL,
+			expectError: "certificate",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			chart := Chart{
+				Repository: tc.repository,
+				ChartName:  "hello",
+				Version:    "0.1.0",
+				Auth:       tc.auth,
+			}
+
+			dir, err := chart.DownloadChart(t.TempDir())
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.FileExists(t, filepath.Join(dir, "Chart.yaml"))
+		})
+	}
+}
@@ -1,16 +1,17 @@
 package helm
 
 import (
+	"bytes"
 	"fmt"
-	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
-	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/cli"
-	"helm.sh/helm/v3/pkg/getter"
+	"github.com/amir-ahmad/kogen/internal/cache"
+	godigest "github.com/opencontainers/go-digest"
+	"golang.org/x/sync/singleflight"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/repo"
 )
@@ -20,6 +21,10 @@ type Chart struct {
 	Repository string
 	ChartName  string
 	Version    string
+
+	// Auth is used to access the repository. Settings that aren't set are read from the
+	// environment and helm's config files.
+	Auth Auth
 }
 
 // ChartType represents the type of chart.
@@ -48,9 +53,26 @@ func (c Chart) GetChartURL(cacheDir string) (string, error) {
 		return c.Repository, nil
 	}
 
+	auth, err := c.resolveAuth()
+	if err != nil {
+		return "", err
+	}
+
+	g, err := newHTTPGetter(c.Repository, auth)
+	if err != nil {
+		return "", err
+	}
+
+	return c.chartURL(cacheDir, g)
+}
+
+// chartURL looks up the artifact url of a chart version in the repository index, which is
+// downloaded with g.
+func (c Chart) chartURL(cacheDir string, g *httpGetter) (string, error) {
+	// The name determines the index file in the cache, so it must be unique per repository.
 	chartRepo, err := repo.NewChartRepository(
-		&repo.Entry{URL: c.Repository},
-		getter.All(&cli.EnvSettings{}),
+		&repo.Entry{Name: c.repositoryName(), URL: c.Repository},
+		g.providers(),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to initialise ChartRepository '%s': %w", c.Repository, err)
@@ -87,13 +109,66 @@ func (c Chart) GetChartURL(cacheDir string) (string, error) {
 	return absoluteChartURL, nil
 }
 
+// pathReplacer normalises a URL into a single path segment.
+var pathReplacer = strings.NewReplacer(":/", "-", ".", "-", "/", "-", ":", "-")
+
 // extractPath returns a normalised path to extract the chart to.
 func (c Chart) extractPath() string {
 	p := path.Join(c.Repository, c.ChartName)
-	return strings.NewReplacer(":/", "-", ".", "-", "/", "-", ":", "-").Replace(p) + "-" + c.Version
+	return pathReplacer.Replace(p) + "-" + c.Version
+}
+
+// repositoryName returns a normalised name for the chart repository.
+func (c Chart) repositoryName() string {
+	return pathReplacer.Replace(strings.TrimSuffix(c.Repository, "/"))
+}
+
+// chartDirName returns the name of the directory the chart archive extracts to.
+func (c Chart) chartDirName() string {
+	if registry.IsOCI(c.Repository) {
+		return filepath.Base(c.Repository)
+	}
+	return c.ChartName
+}
+
+// chartCacheDir returns the directory in cacheDir that holds the extracted chart and its
+// digest.
+func (c Chart) chartCacheDir(cacheDir string) string {
+	return filepath.Join(cacheDir, c.extractPath())
+}
+
+// extractedDir returns the directory in cacheDir that the chart is extracted to.
+func (c Chart) extractedDir(cacheDir string) string {
+	return filepath.Join(c.chartCacheDir(cacheDir), c.chartDirName())
 }
 
+// digestFile is the name of the file the digest of a chart is stored in.
+const digestFile = "digest"
+
+// IsCached returns whether the chart has already been downloaded to cacheDir.
+func (c Chart) IsCached(cacheDir string) bool {
+	_, err := os.Stat(filepath.Join(c.chartCacheDir(cacheDir), digestFile))
+	return err == nil
+}
+
+// Digest returns the digest of a chart downloaded to cacheDir. This is the manifest digest for
+// charts in OCI registries, and the digest of the chart archive otherwise.
+func (c Chart) Digest(cacheDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(c.chartCacheDir(cacheDir), digestFile))
+	if err != nil {
+		return "", fmt.Errorf("when reading chart digest: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// downloads deduplicates concurrent downloads of the same chart within this process.
+var downloads singleflight.Group
+
 // DownloadChart downloads a chart to a local directory and returns the extracted directory path.
+//
+// It is safe to call concurrently, including from several processes sharing cacheDir. Charts
+// are downloaded to a temporary directory and renamed into place, so a partially extracted
+// chart is never visible.
 func (c Chart) DownloadChart(cacheDir string) (string, error) {
 	// Create dir if it doesn't exist
 	err := os.MkdirAll(cacheDir, 0o755)
@@ -101,51 +176,124 @@ func (c Chart) DownloadChart(cacheDir string) (string, error) {
 		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Get the full path that the chart will be extracted to.
-	var chartExtractedDir string
-	if registry.IsOCI(c.Repository) {
-		chartExtractedDir = filepath.Join(cacheDir, c.extractPath(), filepath.Base(c.Repository))
-	} else {
-		chartExtractedDir = filepath.Join(cacheDir, c.extractPath(), c.ChartName)
+	// If the chart has already been downloaded, don't redownload
+	if c.IsCached(cacheDir) {
+		cache.Touch(c.chartCacheDir(cacheDir))
+		return c.extractedDir(cacheDir), nil
 	}
 
-	// If extracted directory already exists, don't redownload
-	if _, err := os.Stat(chartExtractedDir); err == nil {
-		return chartExtractedDir, nil
+	_, err, _ = downloads.Do(c.chartCacheDir(cacheDir), func() (interface{}, error) {
+		return nil, c.pullChart(cacheDir)
+	})
+	if err != nil {
+		return "", err
 	}
 
-	// Initialise helm action config
-	config := new(action.Configuration)
-	if err := config.Init(nil, "", "secret", log.Printf); err != nil {
-		return "", fmt.Errorf("failed to initialise helm action configuration: %w", err)
+	return c.extractedDir(cacheDir), nil
+}
+
+// pullChart pulls a chart into a temporary directory in cacheDir, extracts it and records its
+// digest, then moves it into place.
+func (c Chart) pullChart(cacheDir string) error {
+	// Another process may have finished downloading the chart since it was last checked.
+	if c.IsCached(cacheDir) {
+		return nil
 	}
 
-	chartURL, err := c.GetChartURL(cacheDir)
+	tmpDir, err := os.MkdirTemp(cacheDir, ".download-")
 	if err != nil {
-		return "", fmt.Errorf("when getting chart url: %w", err)
+		return fmt.Errorf("failed to create temporary directory: %w", err)
 	}
+	defer os.RemoveAll(tmpDir) //nolint:errcheck
 
-	// If the chart is an OCI chart, we need to set up registry to pull with auth
+	var archive []byte
+	var digest string
 	if registry.IsOCI(c.Repository) {
-		config.RegistryClient, err = registry.NewClient()
-		if err != nil {
-			return "", fmt.Errorf("failed to create registry client: %w", err)
+		archive, digest, err = c.pullOCIArchive()
+	} else {
+		archive, digest, err = c.pullArchive(cacheDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := chartutil.Expand(tmpDir, bytes.NewReader(archive)); err != nil {
+		return fmt.Errorf("when extracting chart: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, c.chartDirName())); err != nil {
+		return fmt.Errorf("chart archive doesn't contain %s: %w", c.chartDirName(), err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, digestFile), []byte(digest+"\n"), 0o644); err != nil {
+		return fmt.Errorf("when writing chart digest: %w", err)
+	}
+
+	// Charts cached by older versions of kogen have no digest and are replaced.
+	chartCacheDir := c.chartCacheDir(cacheDir)
+	if _, err := os.Stat(chartCacheDir); err == nil {
+		if err := os.RemoveAll(chartCacheDir); err != nil {
+			return fmt.Errorf("when removing outdated chart from cache: %w", err)
+		}
+	}
+
+	// Renaming fails if another process moved the same chart into place first, in which case
+	// its copy is used.
+	if err := os.Rename(tmpDir, chartCacheDir); err != nil {
+		if c.IsCached(cacheDir) {
+			return nil
 		}
+		return fmt.Errorf("when moving chart into cache: %w", err)
+	}
+
+	return nil
+}
+
+// pullArchive downloads the chart archive from a chart repository, and returns it along with
+// its digest.
+func (c Chart) pullArchive(cacheDir string) ([]byte, string, error) {
+	auth, err := c.resolveAuth()
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Initialise pull with config
-	pull := action.NewPullWithOpts(action.WithConfig(config))
-	pull.Settings = cli.New()
-	pull.DestDir = cacheDir
-	pull.Untar = true
-	pull.Version = c.Version
-	pull.UntarDir = c.extractPath()
+	g, err := newHTTPGetter(c.Repository, auth)
+	if err != nil {
+		return nil, "", err
+	}
+
+	chartURL, err := c.chartURL(cacheDir, g)
+	if err != nil {
+		return nil, "", fmt.Errorf("when getting chart url: %w", err)
+	}
+
+	buf, err := g.Get(chartURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("when pulling chart: %w", err)
+	}
+
+	archive := buf.Bytes()
+	return archive, godigest.FromBytes(archive).String(), nil
+}
+
+// pullOCIArchive pulls the chart archive from an OCI registry, and returns it along with the
+// digest of its manifest.
+func (c Chart) pullOCIArchive() ([]byte, string, error) {
+	auth, err := c.resolveAuth()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := c.newRegistryClient(auth)
+	if err != nil {
+		return nil, "", err
+	}
 
-	// Download chart
-	_, err = pull.Run(chartURL)
+	ref := fmt.Sprintf("%s:%s", strings.TrimPrefix(c.Repository, "oci://"), c.Version)
+	result, err := client.Pull(ref, registry.PullOptWithChart(true))
 	if err != nil {
-		return "", fmt.Errorf("when pulling chart: %w", err)
+		return nil, "", fmt.Errorf("when pulling chart: %w", err)
 	}
 
-	return chartExtractedDir, nil
+	return result.Chart.Data, result.Manifest.Digest, nil
 }
@@ -1,9 +1,22 @@
 package helm
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
 func TestExtractPath(t *testing.T) {
@@ -69,3 +82,165 @@ func TestExtractPath(t *testing.T) {
 		})
 	}
 }
+
+// newTestRepository serves a chart repository containing a single chart named hello at
+// version 0.1.0, and returns the repository URL.
+func newTestRepository(t *testing.T) string {
+	t.Helper()
+	return serveTestRepository(t, httptest.NewServer, nil).URL
+}
+
+// serveTestRepository serves the chart repository of newTestRepository with newServer. Requests
+// are passed through wrap when it is set.
+func serveTestRepository(
+	t *testing.T,
+	newServer func(http.Handler) *httptest.Server,
+	wrap func(http.Handler) http.Handler,
+) *httptest.Server {
+	t.Helper()
+
+	chartDir := filepath.Join(t.TempDir(), "hello")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "Chart.yaml"),
+		[]byte("apiVersion: v2\nname: hello\nversion: 0.1.0\n"),
+		0o644,
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "templates", "configmap.yaml"),
+		[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: hello\n"),
+		0o644,
+	))
+
+	chart, err := loader.LoadDir(chartDir)
+	require.NoError(t, err)
+
+	repoDir := t.TempDir()
+	_, err = chartutil.Save(chart, repoDir)
+	require.NoError(t, err)
+
+	var handler http.Handler = http.FileServer(http.Dir(repoDir))
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := newServer(handler)
+	t.Cleanup(server.Close)
+
+	index, err := repo.IndexDirectory(repoDir, server.URL)
+	require.NoError(t, err)
+	require.NoError(t, index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0o644))
+
+	return server
+}
+
+func TestDownloadChart_Concurrent(t *testing.T) {
+	repoURL := newTestRepository(t)
+	cacheDir := t.TempDir()
+
+	chart := Chart{
+		Repository: repoURL,
+		ChartName:  "hello",
+		Version:    "0.1.0",
+	}
+
+	const downloads = 8
+	dirs := make([]string, downloads)
+	errs := make([]error, downloads)
+	var wg sync.WaitGroup
+	for i := range downloads {
+		wg.Go(func() {
+			dirs[i], errs[i] = chart.DownloadChart(cacheDir)
+		})
+	}
+	wg.Wait()
+
+	expectedDir := filepath.Join(cacheDir, chart.extractPath(), "hello")
+	for i := range downloads {
+		require.NoError(t, errs[i])
+		assert.Equal(t, expectedDir, dirs[i])
+	}
+	assert.FileExists(t, filepath.Join(expectedDir, "Chart.yaml"))
+
+	// No temporary directories are left behind.
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), ".download-"), entry.Name())
+	}
+
+	// The index file is named after the repository.
+	assert.FileExists(t, filepath.Join(cacheDir, chart.repositoryName()+"-index.yaml"))
+}
+
+func TestIsCached(t *testing.T) {
+	repoURL := newTestRepository(t)
+	cacheDir := t.TempDir()
+
+	chart := Chart{
+		Repository: repoURL,
+		ChartName:  "hello",
+		Version:    "0.1.0",
+	}
+	assert.False(t, chart.IsCached(cacheDir))
+
+	_, err := chart.DownloadChart(cacheDir)
+	require.NoError(t, err)
+	assert.True(t, chart.IsCached(cacheDir))
+
+	other := Chart{
+		Repository: repoURL,
+		ChartName:  "hello",
+		Version:    "0.2.0",
+	}
+	assert.False(t, other.IsCached(cacheDir))
+}
+
+func TestDigest(t *testing.T) {
+	repoURL := newTestRepository(t)
+	cacheDir := t.TempDir()
+
+	chart := Chart{
+		Repository: repoURL,
+		ChartName:  "hello",
+		Version:    "0.1.0",
+	}
+
+	_, err := chart.Digest(cacheDir)
+	require.Error(t, err, "chart isn't downloaded")
+
+	_, err = chart.DownloadChart(cacheDir)
+	require.NoError(t, err)
+
+	resp, err := http.Get(repoURL + "/hello-0.1.0.tgz")
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck
+	archive, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	digest, err := chart.Digest(cacheDir)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(archive)), digest)
+}
+
+func TestDownloadChart_ReplacesChartWithoutDigest(t *testing.T) {
+	repoURL := newTestRepository(t)
+	cacheDir := t.TempDir()
+
+	chart := Chart{
+		Repository: repoURL,
+		ChartName:  "hello",
+		Version:    "0.1.0",
+	}
+
+	// Charts used to be cached without a digest.
+	staleFile := filepath.Join(chart.extractedDir(cacheDir), "stale.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(staleFile), 0o755))
+	require.NoError(t, os.WriteFile(staleFile, nil, 0o644))
+	assert.False(t, chart.IsCached(cacheDir))
+
+	dir, err := chart.DownloadChart(cacheDir)
+	require.NoError(t, err)
+	assert.True(t, chart.IsCached(cacheDir))
+	assert.FileExists(t, filepath.Join(dir, "Chart.yaml"))
+	assert.NoFileExists(t, staleFile)
+}
@@ -0,0 +1,103 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"helm.sh/helm/v3/pkg/engine"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	util_yaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// lookupExtensions are the extensions of files read from a lookup directory.
+var lookupExtensions = []string{".yaml", ".yml", ".json"}
+
+// fixtureClientProvider implements engine.ClientProvider with objects read from files, so that
+// the lookup template function finds them as if they were in a cluster.
+type fixtureClientProvider struct {
+	objects []*unstructured.Unstructured
+}
+
+// Compile time check to ensure fixtureClientProvider implements engine.ClientProvider.
+var _ engine.ClientProvider = (*fixtureClientProvider)(nil)
+
+// newFixtureClientProvider reads the objects in the files of dir and its subdirectories.
+func newFixtureClientProvider(dir string) (*fixtureClientProvider, error) {
+	p := &fixtureClientProvider{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains(lookupExtensions, filepath.Ext(path)) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		decoder := util_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			var obj *unstructured.Unstructured
+			if err := decoder.Decode(&obj); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return fmt.Errorf("when decoding %s: %w", path, err)
+			}
+			if obj == nil {
+				continue
+			}
+			p.objects = append(p.objects, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("when reading lookup directory: %w", err)
+	}
+
+	return p, nil
+}
+
+// GetClientFor implements engine.ClientProvider. The resource is guessed from the kind, and the
+// kind is treated as namespaced unless its objects have no namespace.
+func (p *fixtureClientProvider) GetClientFor(
+	apiVersion, kind string,
+) (dynamic.NamespaceableResourceInterface, bool, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, false, fmt.Errorf("when parsing apiVersion %s: %w", apiVersion, err)
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gv.WithKind(kind))
+
+	var objects []runtime.Object
+	namespaced := true
+	for _, obj := range p.objects {
+		if obj.GetAPIVersion() != apiVersion || obj.GetKind() != kind {
+			continue
+		}
+		objects = append(objects, obj.DeepCopy())
+		if obj.GetNamespace() == "" {
+			namespaced = false
+		}
+	}
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: kind + "List"},
+		objects...,
+	)
+	return client.Resource(gvr), namespaced, nil
+}
@@ -0,0 +1,115 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_Lookup(t *testing.T) {
+	lookupDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(lookupDir, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(lookupDir, "secrets.yaml"), []byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: existing
+  namespace: default
+data:
+  password: c2VjcmV0
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: other
+  namespace: other
+`), 0o644))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(lookupDir, "nested", "namespace.json"),
+		[]byte(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "default"}}`),
+		0o644,
+	))
+	require.NoError(t, os.WriteFile(filepath.Join(lookupDir, "README.md"), []byte("ignored"), 0o644))
+
+	tests := map[string]struct {
+		template    string
+		lookupDir   string
+		expected    string
+		expectError string
+	}{
+		"finds object": {
+			template:  `{{ (lookup "v1" "Secret" "default" "existing").data.password }}`,
+			lookupDir: lookupDir,
+			expected:  "c2VjcmV0",
+		},
+		"missing object is empty": {
+			template:  `{{ lookup "v1" "Secret" "default" "missing" | len }}`,
+			lookupDir: lookupDir,
+			expected:  "0",
+		},
+		"object in other namespace is not found": {
+			template:  `{{ lookup "v1" "Secret" "default" "other" | len }}`,
+			lookupDir: lookupDir,
+			expected:  "0",
+		},
+		"lists objects in namespace": {
+			template:  `{{ range (lookup "v1" "Secret" "other" "").items }}{{ .metadata.name }}{{ end }}`,
+			lookupDir: lookupDir,
+			expected:  "other",
+		},
+		"lists objects in all namespaces": {
+			template:  `{{ (lookup "v1" "Secret" "" "").items | len }}`,
+			lookupDir: lookupDir,
+			expected:  "2",
+		},
+		"finds cluster scoped object": {
+			template:  `{{ (lookup "v1" "Namespace" "" "default").metadata.name }}`,
+			lookupDir: lookupDir,
+			expected:  "default",
+		},
+		"kind without objects is empty": {
+			template:  `{{ lookup "apps/v1" "Deployment" "default" "existing" | len }}`,
+			lookupDir: lookupDir,
+			expected:  "0",
+		},
+		"no lookup dir is empty": {
+			template: `{{ lookup "v1" "Secret" "default" "existing" | len }}`,
+			expected: "0",
+		},
+		"missing lookup dir": {
+			template:    `{{ lookup "v1" "Secret" "default" "existing" | len }}`,
+			lookupDir:   filepath.Join(lookupDir, "missing"),
+			expectError: "when reading lookup directory",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			chartDir := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0o755))
+			require.NoError(t, os.WriteFile(
+				filepath.Join(chartDir, "Chart.yaml"),
+				[]byte("apiVersion: v2\nname: test\nversion: 0.1.0\n"),
+				0o644,
+			))
+			require.NoError(t, os.WriteFile(
+				filepath.Join(chartDir, "templates", "lookup.yaml"),
+				[]byte("result: "+tc.template+"\n"),
+				0o644,
+			))
+
+			rendered, err := Release{Release: "test", LookupDir: tc.lookupDir}.Template(chartDir)
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{
+				"test/templates/lookup.yaml-manifest-0": "result: " + tc.expected,
+			}, rendered)
+		})
+	}
+}
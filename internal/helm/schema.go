@@ -0,0 +1,156 @@
+package helm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// SchemaViolation is a value that doesn't match a values.schema.json of a chart.
+type SchemaViolation struct {
+	// Chart is the name of the chart whose schema was violated.
+	Chart string
+	// Path is the location of the value within the values of the release, including the names
+	// of dependencies for their values.
+	Path []string
+	// Message describes the violation.
+	Message string
+}
+
+// SchemaError is returned when the values of a release don't match the schemas of its chart.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+// Error implements error.
+func (e *SchemaError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("values don't match the chart schema:")
+	for _, v := range e.Violations {
+		fmt.Fprintf(&sb, "\n  %s: at %s: %s", v.Chart, ValuesPath(v.Path), v.Message) //nolint:errcheck
+	}
+	return sb.String()
+}
+
+// ValuesPath formats the path of a value, such as .image.tag.
+func ValuesPath(path []string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	return "." + strings.Join(path, ".")
+}
+
+// schemaPrinter prints the messages of schema violations.
+var schemaPrinter = message.NewPrinter(language.English)
+
+// validateValues validates values against the schema of the chart and those of its
+// dependencies, like helm does when rendering. Remote references in schemas are not followed,
+// helm still validates them when rendering.
+func validateValues(chrt *chart.Chart, values map[string]interface{}) error {
+	violations, err := schemaViolations(chrt, values, nil)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	// The order of violations isn't stable, so they're sorted by path.
+	slices.SortStableFunc(violations, func(a, b SchemaViolation) int {
+		if c := slices.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Message, b.Message)
+	})
+	return &SchemaError{Violations: violations}
+}
+
+func schemaViolations(
+	chrt *chart.Chart,
+	values map[string]interface{},
+	path []string,
+) ([]SchemaViolation, error) {
+	var violations []SchemaViolation
+
+	if chrt.Schema != nil {
+		schema, err := compileSchema(chrt.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("when compiling values schema of chart %s: %w", chrt.Name(), err)
+		}
+
+		var validationErr *jsonschema.ValidationError
+		if err := schema.Validate(values); errors.As(err, &validationErr) {
+			for _, leaf := range leafErrors(validationErr) {
+				violations = append(violations, SchemaViolation{
+					Chart:   chrt.Name(),
+					Path:    append(append([]string{}, path...), leaf.InstanceLocation...),
+					Message: leaf.ErrorKind.LocalizedString(schemaPrinter),
+				})
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("when validating values of chart %s: %w", chrt.Name(), err)
+		}
+	}
+
+	for _, dependency := range chrt.Dependencies() {
+		dependencyValues, ok := values[dependency.Name()].(map[string]interface{})
+		if !ok {
+			// helm reports values of the wrong type itself.
+			continue
+		}
+		dependencyViolations, err := schemaViolations(
+			dependency,
+			dependencyValues,
+			append(append([]string{}, path...), dependency.Name()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, dependencyViolations...)
+	}
+
+	return violations, nil
+}
+
+// compileSchema compiles a values.schema.json. References to other files or URLs are treated
+// as allowing any value.
+func compileSchema(schemaJSON []byte) (*jsonschema.Schema, error) {
+	schema, err := jsonschema.UnmarshalJSON(bytes.NewReader(schemaJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(permissiveLoader{})
+	if err := compiler.AddResource("file:///values.schema.json", schema); err != nil {
+		return nil, err
+	}
+	return compiler.Compile("file:///values.schema.json")
+}
+
+// permissiveLoader loads a schema that allows any value for every URL.
+type permissiveLoader struct{}
+
+// Load implements jsonschema.URLLoader.
+func (permissiveLoader) Load(string) (any, error) {
+	return true, nil
+}
+
+// leafErrors returns the errors without causes, which are the individual violations.
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
@@ -0,0 +1,81 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestValidateValues(t *testing.T) {
+	dependency := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "dependency"},
+		Schema:   []byte(`{"properties": {"enabled": {"type": "boolean"}}}`),
+	}
+	parent := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "parent"},
+		Schema: []byte(`{
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string"},
+				"ports": {"items": {"type": "integer"}}
+			}
+		}`),
+	}
+	parent.SetDependencies(dependency)
+
+	tests := map[string]struct {
+		chart       *chart.Chart
+		values      map[string]interface{}
+		expected    []SchemaViolation
+		expectError string
+	}{
+		"valid values": {
+			chart:  parent,
+			values: map[string]interface{}{"name": "hello", "ports": []interface{}{80}},
+		},
+		"chart without schema": {
+			chart:  &chart.Chart{Metadata: &chart.Metadata{Name: "noschema"}},
+			values: map[string]interface{}{"name": 1},
+		},
+		"violations are sorted by path": {
+			chart: parent,
+			values: map[string]interface{}{
+				"ports":      []interface{}{80, "https"},
+				"dependency": map[string]interface{}{"enabled": "yes"},
+			},
+			expected: []SchemaViolation{
+				{Chart: "parent", Path: []string{}, Message: "missing property 'name'"},
+				{Chart: "dependency", Path: []string{"dependency", "enabled"}, Message: "got string, want boolean"},
+				{Chart: "parent", Path: []string{"ports", "1"}, Message: "got string, want integer"},
+			},
+		},
+		"invalid schema": {
+			chart: &chart.Chart{
+				Metadata: &chart.Metadata{Name: "invalid"},
+				Schema:   []byte(`{"type": 1}`),
+			},
+			values:      map[string]interface{}{},
+			expectError: "when compiling values schema of chart invalid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateValues(tc.chart, tc.values)
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			if tc.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			var schemaErr *SchemaError
+			require.ErrorAs(t, err, &schemaErr)
+			assert.Equal(t, tc.expected, schemaErr.Violations)
+		})
+	}
+}
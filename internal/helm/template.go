@@ -5,6 +5,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/mitchellh/copystructure"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/engine"
@@ -17,8 +18,10 @@ type Release struct {
 	Namespace   string                 `json:"namespace"`
 	IncludeCRDs bool                   `json:"includeCRDs"`
 	Values      map[string]interface{} `json:"values"`
+	ValuesFiles []string               `json:"valuesFiles"`
 	KubeVersion string                 `json:"kubeVersion"`
 	APIVersions []string               `json:"apiVersions"`
+	LookupDir   string                 `json:"lookupDir"`
 }
 
 // Template does the equivalent of a `helm template`
@@ -43,10 +46,25 @@ func (r Release) Template(chartPath string) (map[string]string, error) {
 		capabilities.APIVersions = r.APIVersions
 	}
 
-	if err := chartutil.ProcessDependenciesWithMerge(chart, r.Values); err != nil {
+	values, err := r.mergeValues()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ProcessDependenciesWithMerge(chart, values); err != nil {
 		return nil, fmt.Errorf("processing chart dependencies: %w", err)
 	}
 
+	// Values are validated before helm does so that violations can be traced back to where the
+	// values were set.
+	coalescedValues, err := chartutil.CoalesceValues(chart, values)
+	if err != nil {
+		return nil, fmt.Errorf("coalescing values: %w", err)
+	}
+	if err := validateValues(chart, coalescedValues); err != nil {
+		return nil, err
+	}
+
 	releaseOptions := chartutil.ReleaseOptions{
 		Name:      r.Release,
 		Namespace: r.Namespace,
@@ -54,16 +72,27 @@ func (r Release) Template(chartPath string) (map[string]string, error) {
 	}
 
 	// Merge chart values with our provided ones
-	renderValues, err := chartutil.ToRenderValues(chart, r.Values, releaseOptions, capabilities)
+	renderValues, err := chartutil.ToRenderValues(chart, values, releaseOptions, capabilities)
 	if err != nil {
 		return nil, fmt.Errorf("preparing render values: %w", err)
 	}
 
-	// Run Helm template
-	engine := engine.Engine{}
-	renderedTemplates, err := engine.Render(chart, renderValues)
-	if err != nil {
-		return nil, fmt.Errorf("rendering helm templates: %w", err)
+	// Run Helm template. lookup returns nothing unless objects are provided in the lookup dir.
+	var renderedTemplates map[string]string
+	if r.LookupDir != "" {
+		provider, err := newFixtureClientProvider(r.LookupDir)
+		if err != nil {
+			return nil, err
+		}
+		renderedTemplates, err = engine.RenderWithClientProvider(chart, renderValues, provider)
+		if err != nil {
+			return nil, fmt.Errorf("rendering helm templates: %w", err)
+		}
+	} else {
+		renderedTemplates, err = engine.Engine{}.Render(chart, renderValues)
+		if err != nil {
+			return nil, fmt.Errorf("rendering helm templates: %w", err)
+		}
 	}
 
 	// Iterate through charts CRDs and add to map
@@ -101,3 +130,29 @@ func (r Release) Template(chartPath string) (map[string]string, error) {
 
 	return out, nil
 }
+
+// mergeValues merges the values files in order, followed by the inline values, in the same way
+// as helm merges the --values and --set flags. Later values take precedence, and nulls are kept
+// so that they remove defaults from the chart.
+func (r Release) mergeValues() (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	for _, file := range r.ValuesFiles {
+		values, err := chartutil.ReadValuesFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading values file %s: %w", file, err)
+		}
+		merged = chartutil.MergeTables(values, merged)
+	}
+
+	if len(r.Values) == 0 {
+		return merged, nil
+	}
+
+	// The inline values are copied as merging modifies them.
+	inline, err := copystructure.Copy(r.Values)
+	if err != nil {
+		return nil, fmt.Errorf("copying values: %w", err)
+	}
+
+	return chartutil.MergeTables(inline.(map[string]interface{}), merged), nil
+}
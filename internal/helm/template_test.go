@@ -0,0 +1,81 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeValues(t *testing.T) {
+	dir := t.TempDir()
+	writeValues := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	first := writeValues("first.yaml", "a: 1\nnested:\n  x: 1\n  z: 1\n")
+	second := writeValues("second.yaml", "a: 2\nnested:\n  z: 2\nremoved: null\n")
+
+	tests := map[string]struct {
+		release     Release
+		expected    map[string]interface{}
+		expectError string
+	}{
+		"no values": {
+			expected: map[string]interface{}{},
+		},
+		"later files take precedence": {
+			release: Release{ValuesFiles: []string{first, second}},
+			expected: map[string]interface{}{
+				"a":       float64(2),
+				"nested":  map[string]interface{}{"x": float64(1), "z": float64(2)},
+				"removed": nil,
+			},
+		},
+		"inline values take precedence": {
+			release: Release{
+				ValuesFiles: []string{first},
+				Values: map[string]interface{}{
+					"nested": map[string]interface{}{"x": "inline"},
+				},
+			},
+			expected: map[string]interface{}{
+				"a":      float64(1),
+				"nested": map[string]interface{}{"x": "inline", "z": float64(1)},
+			},
+		},
+		"missing file": {
+			release:     Release{ValuesFiles: []string{filepath.Join(dir, "missing.yaml")}},
+			expectError: "reading values file",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			values, err := tc.release.mergeValues()
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, values)
+		})
+	}
+}
+
+func TestMergeValues_DoesNotModifyInlineValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("nested:\n  z: 1\n"), 0o644))
+
+	release := Release{
+		ValuesFiles: []string{path},
+		Values:      map[string]interface{}{"nested": map[string]interface{}{"x": 1}},
+	}
+	_, err := release.mergeValues()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"nested": map[string]interface{}{"x": 1}}, release.Values)
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/encoding/jsonschema"
+	"cuelang.org/go/encoding/yaml"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// ValuesDefinition is the name of the definition generated for the values of a chart.
+const ValuesDefinition = "#Values"
+
+// Chart returns a cue file with a #Values definition for the values of the chart at chartPath.
+// The definition is converted from the values.schema.json of the chart, or inferred from its
+// values.yaml when it has no schema.
+func Chart(chartPath, pkgName string) ([]byte, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	if chrt.Schema != nil {
+		return schemaValues(chrt.Schema, pkgName)
+	}
+
+	for _, f := range chrt.Raw {
+		if f.Name == "values.yaml" {
+			return inferValues(f.Data, pkgName)
+		}
+	}
+
+	return nil, fmt.Errorf("chart %s has neither a values.schema.json nor a values.yaml", chrt.Name())
+}
+
+// schemaValues converts a values.schema.json to a #Values definition.
+func schemaValues(schemaJSON []byte, pkgName string) ([]byte, error) {
+	schema := cuecontext.New().CompileBytes(schemaJSON, cue.Filename("values.schema.json"))
+	if err := schema.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse values.schema.json: %w", err)
+	}
+
+	f, err := jsonschema.Extract(schema, &jsonschema.Config{PkgName: pkgName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert values.schema.json to cue: %w", err)
+	}
+
+	var defs definitions
+	if err := defs.add(ValuesDefinition, f); err != nil {
+		return nil, fmt.Errorf("failed to convert values.schema.json to cue: %w", err)
+	}
+	return defs.format(pkgName)
+}
+
+// inferValues infers a #Values definition from a values.yaml. Every value is optional as the
+// chart has defaults for them, and objects are open as charts often accept keys that don't
+// have defaults. Comments in the values.yaml are kept as documentation.
+func inferValues(valuesYAML []byte, pkgName string) ([]byte, error) {
+	yamlFile, err := yaml.Extract("values.yaml", valuesYAML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse values.yaml: %w", err)
+	}
+	values := cuecontext.New().BuildFile(yamlFile)
+	if err := values.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse values.yaml: %w", err)
+	}
+
+	root := inferType(values)
+	decls := []ast.Decl{&ast.EmbedDecl{Expr: root}}
+	if s, ok := root.(*ast.StructLit); ok {
+		decls = s.Elts
+	}
+
+	var defs definitions
+	if err := defs.add(ValuesDefinition, &ast.File{Decls: decls}); err != nil {
+		return nil, err
+	}
+	return defs.format(pkgName)
+}
+
+// inferType returns the type of a value from a values.yaml. Numbers are typed as number rather
+// than int, as a default such as 1 is often replaced with a fraction.
+func inferType(v cue.Value) ast.Expr {
+	switch v.Kind() {
+	case cue.StructKind:
+		s := &ast.StructLit{}
+		iter, err := v.Fields()
+		if err != nil {
+			return ast.NewPredeclared("_")
+		}
+		for iter.Next() {
+			field := &ast.Field{
+				Label:      ast.NewStringLabel(iter.Selector().Unquoted()),
+				Constraint: token.OPTION,
+				Value:      inferType(iter.Value()),
+			}
+			ast.SetComments(field, iter.Value().Doc())
+			s.Elts = append(s.Elts, field)
+		}
+		s.Elts = append(s.Elts, &ast.Ellipsis{})
+		return s
+	case cue.ListKind:
+		return &ast.ListLit{Elts: []ast.Expr{&ast.Ellipsis{Type: inferElementType(v)}}}
+	case cue.StringKind:
+		return ast.NewPredeclared("string")
+	case cue.BoolKind:
+		return ast.NewPredeclared("bool")
+	case cue.IntKind, cue.FloatKind:
+		return ast.NewPredeclared("number")
+	default:
+		// Nulls are often used for values without a default, so their type is unknown.
+		return ast.NewPredeclared("_")
+	}
+}
+
+// inferElementType returns the type of the elements of a list when they are all scalars of
+// the same kind, counting ints and floats as numbers, and allows any value otherwise.
+func inferElementType(list cue.Value) ast.Expr {
+	iter, err := list.List()
+	if err != nil {
+		return ast.NewPredeclared("_")
+	}
+
+	var kind cue.Kind
+	var elem cue.Value
+	for i := 0; iter.Next(); i++ {
+		k := iter.Value().Kind()
+		if k == cue.IntKind {
+			k = cue.FloatKind
+		}
+		if k == cue.StructKind || k == cue.ListKind || (i > 0 && k != kind) {
+			return ast.NewPredeclared("_")
+		}
+		kind, elem = k, iter.Value()
+	}
+	if kind == cue.BottomKind {
+		return ast.NewPredeclared("_")
+	}
+	return inferType(elem)
+}
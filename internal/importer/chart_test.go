@@ -0,0 +1,62 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferValues(t *testing.T) {
+	tests := map[string]struct {
+		values   string
+		expected string
+	}{
+		"scalars": {
+			values: "name: hello\nreplicas: 1\nratio: 0.5\nenabled: false\nempty: null\n",
+			expected: `#Values: {
+	name?:     string
+	replicas?: number
+	ratio?:    number
+	enabled?:  bool
+	empty?:    _
+	...
+}
+`,
+		},
+		"lists": {
+			values: "ports: [80, 443]\nweights: [1, 0.5]\nmixed: [1, a]\nobjects: [{a: 1}]\nempty: []\n",
+			expected: `#Values: {
+	ports?: [...number]
+	weights?: [...number]
+	mixed?: [...]
+	objects?: [...]
+	empty?: [...]
+	...
+}
+`,
+		},
+		"labels are quoted and shadowed types are renamed": {
+			values: "my-key: a\nstring: b\n",
+			expected: `#Values: {
+	"my-key"?: __string
+	string?:   __string
+	...
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			src, err := inferValues([]byte(tc.values), "")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(src))
+		})
+	}
+}
+
+func TestSchemaValues_DefinitionConflict(t *testing.T) {
+	_, err := schemaValues([]byte(`{"definitions": {"Values": {"type": "string"}}}`), "values")
+	require.ErrorContains(t, err, "definition #Values is defined more than once")
+}
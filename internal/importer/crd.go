@@ -0,0 +1,187 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/encoding/jsonschema"
+	"github.com/amir-ahmad/kogen/internal/cache"
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/amir-ahmad/kogen/internal/validate"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// crdExtensions are the extensions of files read from a directory of CRDs.
+var crdExtensions = []string{".yaml", ".yml", ".json"}
+
+// CRDs returns a cue file with a definition for each version of the CustomResourceDefinitions
+// in objects, named after the kind and version such as #CertificateV1. Other objects are
+// ignored.
+func CRDs(objects []*unstructured.Unstructured, pkgName string) ([]byte, error) {
+	var crds []interface{}
+	for _, obj := range objects {
+		if obj.GroupVersionKind().GroupKind() == validate.CRDGroupKind {
+			crds = append(crds, obj.Object)
+		}
+	}
+	if len(crds) == 0 {
+		return nil, fmt.Errorf("no CustomResourceDefinitions found")
+	}
+
+	// The CRDs are compiled from json as ExtractCRDs can't decode encoded go values.
+	crdsJSON, err := json.Marshal(crds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CustomResourceDefinitions: %w", err)
+	}
+	crdsValue := cuecontext.New().CompileBytes(crdsJSON)
+	if err := crdsValue.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse CustomResourceDefinitions: %w", err)
+	}
+
+	extracted, err := jsonschema.ExtractCRDs(crdsValue, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert CustomResourceDefinitions to cue: %w", err)
+	}
+
+	var defs definitions
+	for _, crd := range extracted {
+		for _, version := range crd.Data.Spec.Versions {
+			f := crd.Versions[version.Name]
+			optionalNamespace(f)
+
+			name := "#" + crd.Data.Spec.Names.Kind + strings.ToUpper(version.Name[:1]) + version.Name[1:]
+			if err := defs.add(name, f); err != nil {
+				return nil, fmt.Errorf("when converting %s: %w", crd.Data.Spec.Names.Kind, err)
+			}
+		}
+	}
+
+	return defs.format(pkgName)
+}
+
+// optionalNamespace makes the namespace of namespaced resources optional, as it is often left
+// for Argo CD or kustomize to set.
+func optionalNamespace(f *ast.File) {
+	for _, decl := range f.Decls {
+		field, ok := decl.(*ast.Field)
+		if !ok || !isLabel(field.Label, "metadata") {
+			continue
+		}
+		metadata, ok := field.Value.(*ast.StructLit)
+		if !ok {
+			continue
+		}
+		for _, elt := range metadata.Elts {
+			if f, ok := elt.(*ast.Field); ok && isLabel(f.Label, "namespace") {
+				f.Constraint = token.OPTION
+			}
+		}
+	}
+}
+
+// isLabel returns whether label is the regular field name.
+func isLabel(label ast.Label, name string) bool {
+	labelName, _, err := ast.LabelName(label)
+	return err == nil && labelName == name
+}
+
+// ReadObjects reads the objects in files, the yaml and json files of directories, and URLs.
+func ReadObjects(sources []string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	for _, source := range sources {
+		if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+			data, err := fetchURL(source)
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := store.DecodeYaml(data)
+			if err != nil {
+				return nil, fmt.Errorf("when parsing %s: %w", source, err)
+			}
+			objects = append(objects, decoded...)
+			continue
+		}
+
+		files, err := sourceFiles(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("when reading %s: %w", file, err)
+			}
+			decoded, err := store.DecodeYaml(data)
+			if err != nil {
+				return nil, fmt.Errorf("when parsing %s: %w", file, err)
+			}
+			objects = append(objects, decoded...)
+		}
+	}
+	return objects, nil
+}
+
+// ChartCRDs reads the CustomResourceDefinitions in the crds directory of the chart at chartPath
+// and its dependencies.
+func ChartCRDs(chartPath string) ([]*unstructured.Unstructured, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, crd := range chrt.CRDObjects() {
+		decoded, err := store.DecodeYaml(crd.File.Data)
+		if err != nil {
+			return nil, fmt.Errorf("when parsing %s: %w", crd.Filename, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// sourceFiles returns the file at path, or the yaml and json files in the directory at path.
+func sourceFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("when accessing %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if !d.IsDir() && slices.Contains(crdExtensions, ext) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("when reading directory %s: %w", path, err)
+	}
+	return files, nil
+}
+
+// fetchURL downloads the content of a URL, retrying after network errors and 429 or 5xx
+// responses.
+func fetchURL(url string) ([]byte, error) {
+	return cache.Request{
+		URL:     url,
+		Timeout: cache.DefaultTimeout,
+		Retries: cache.DefaultRetries,
+	}.Get()
+}
@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/amir-ahmad/kogen/internal/generator/cog/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const widgetCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+    singular: widget
+  scope: Namespaced
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                size:
+                  type: integer
+`
+
+func TestCRDs(t *testing.T) {
+	tests := map[string]struct {
+		manifests   string
+		contains    []string
+		expectError string
+	}{
+		"definition per kind and version": {
+			manifests: widgetCRD + "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: ignored\n",
+			contains: []string{
+				"package crds\n",
+				"#WidgetV1: {",
+				`apiVersion: "example.com/v1"`,
+				`kind:       "Widget"`,
+				"spec?: size?: int",
+			},
+		},
+		"namespace is optional": {
+			manifests: widgetCRD,
+			contains:  []string{"namespace?: string"},
+		},
+		"duplicate definitions": {
+			manifests:   widgetCRD + "---\n" + widgetCRD,
+			expectError: "definition #WidgetV1 is defined more than once",
+		},
+		"no crds": {
+			manifests:   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: ignored\n",
+			expectError: "no CustomResourceDefinitions found",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			objects, err := store.DecodeYaml([]byte(tc.manifests))
+			require.NoError(t, err)
+
+			src, err := CRDs(objects, "crds")
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+			for _, s := range tc.contains {
+				assert.Contains(t, string(src), s)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Package importer converts schemas from other formats into cue definitions.
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+)
+
+// definitions builds a cue file of definitions converted from other schemas.
+type definitions struct {
+	decls []ast.Decl
+	names map[string]bool
+}
+
+// add adds a definition named name for the schema at the top level of f. Definitions in f
+// that the schema references are kept at the top level of the file.
+func (d *definitions) add(name string, f *ast.File) error {
+	if d.names == nil {
+		d.names = map[string]bool{}
+	}
+	define := func(name string) error {
+		if d.names[name] {
+			return fmt.Errorf("definition %s is defined more than once", name)
+		}
+		d.names[name] = true
+		return nil
+	}
+
+	var schema []ast.Decl
+	var defs []ast.Decl
+	for _, decl := range f.Decls {
+		switch x := decl.(type) {
+		case *ast.Package, *ast.ImportDecl, *ast.Attribute:
+			// Imports are added back when the file is sanitized.
+			continue
+		case *ast.Field:
+			if ident, ok := x.Label.(*ast.Ident); ok && strings.HasPrefix(ident.Name, "#") {
+				if err := define(ident.Name); err != nil {
+					return err
+				}
+				defs = append(defs, x)
+				continue
+			}
+		}
+		schema = append(schema, decl)
+	}
+
+	if err := define(name); err != nil {
+		return err
+	}
+	definition := &ast.Field{Label: ast.NewIdent(name), Value: &ast.StructLit{Elts: schema}}
+	ast.SetRelPos(definition, token.NewSection)
+
+	d.decls = append(d.decls, definition)
+	d.decls = append(d.decls, defs...)
+	return nil
+}
+
+// format returns the formatted file of the definitions.
+func (d *definitions) format(pkgName string) ([]byte, error) {
+	f := &ast.File{}
+	if pkgName != "" {
+		f.Decls = append(f.Decls, &ast.Package{Name: ast.NewIdent(pkgName)})
+	}
+	f.Decls = append(f.Decls, d.decls...)
+	return formatFile(f)
+}
+
+// formatFile formats a generated cue file.
+func formatFile(f *ast.File) ([]byte, error) {
+	if err := astutil.Sanitize(f); err != nil {
+		return nil, fmt.Errorf("failed to sanitize cue: %w", err)
+	}
+
+	src, err := format.Node(f, format.Simplify())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format cue: %w", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,216 @@
+package kustomize
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// errReadOnly is returned when kustomize tries to write to the filesystem.
+var errReadOnly = fmt.Errorf("the kustomize filesystem is read-only")
+
+// moduleFS is a read-only view of the files on disk under root, with files held in memory
+// layered on top. Files in memory hide files on disk with the same path, and the kustomization
+// files on disk in hideDir are hidden so that they don't clash with the one in memory.
+type moduleFS struct {
+	root    string
+	dir     string
+	hideDir string
+	memory  filesys.FileSystem
+	disk    filesys.FileSystem
+}
+
+// Compile time check to ensure moduleFS implements filesys.FileSystem.
+var _ filesys.FileSystem = (*moduleFS)(nil)
+
+// newModuleFS returns a moduleFS with files, keyed by their name, in memory in dir. dir must be
+// within root. The kustomization files on disk in dir are only hidden when there are files in
+// memory.
+func newModuleFS(dir, root string, files map[string][]byte) (*moduleFS, error) {
+	root, err := resolvePath(root)
+	if err != nil {
+		return nil, fmt.Errorf("when resolving module root: %w", err)
+	}
+	dir, err = resolvePath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("when resolving kustomization directory: %w", err)
+	}
+	if !within(dir, root) {
+		return nil, fmt.Errorf("kustomization directory %s is outside the module root %s", dir, root)
+	}
+
+	memory := filesys.MakeFsInMemory()
+	for name, data := range files {
+		if err := memory.WriteFile(filepath.Join(dir, name), data); err != nil {
+			return nil, fmt.Errorf("failed to write %s to kustomize memfs: %w", name, err)
+		}
+	}
+
+	m := &moduleFS{
+		root:   root,
+		dir:    dir,
+		memory: memory,
+		disk:   filesys.MakeFsOnDisk(),
+	}
+	if len(files) > 0 {
+		m.hideDir = dir
+	}
+	return m, nil
+}
+
+// resolvePath returns the absolute path of an existing file with symlinks resolved.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// within returns whether path is root or inside it.
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// onDisk returns whether path can be read from disk, which is when it's in the module root after
+// resolving symlinks and isn't hidden.
+func (m *moduleFS) onDisk(path string) bool {
+	resolved, err := resolvePath(path)
+	if err != nil || !within(resolved, m.root) {
+		return false
+	}
+	return !m.hidden(resolved)
+}
+
+// hidden returns whether path is a kustomization file in hideDir.
+func (m *moduleFS) hidden(path string) bool {
+	return filepath.Dir(path) == m.hideDir &&
+		slices.Contains(konfig.RecognizedKustomizationFileNames(), filepath.Base(path))
+}
+
+// checkDisk returns an error explaining why path can't be read from disk. Missing files are
+// left for the filesystem on disk to report.
+func (m *moduleFS) checkDisk(path string) error {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return nil
+	}
+	if !within(resolved, m.root) {
+		return fmt.Errorf("%s is outside the module root %s", path, m.root)
+	}
+	if m.hidden(resolved) {
+		return fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	return nil
+}
+
+func (m *moduleFS) Create(string) (filesys.File, error) { return nil, errReadOnly }
+func (m *moduleFS) Mkdir(string) error                  { return errReadOnly }
+func (m *moduleFS) MkdirAll(string) error               { return errReadOnly }
+func (m *moduleFS) RemoveAll(string) error              { return errReadOnly }
+func (m *moduleFS) WriteFile(string, []byte) error      { return errReadOnly }
+
+func (m *moduleFS) Open(path string) (filesys.File, error) {
+	if m.memory.Exists(path) {
+		return m.memory.Open(path)
+	}
+	if err := m.checkDisk(path); err != nil {
+		return nil, err
+	}
+	return m.disk.Open(path)
+}
+
+func (m *moduleFS) IsDir(path string) bool {
+	return m.memory.IsDir(path) || (m.onDisk(path) && m.disk.IsDir(path))
+}
+
+func (m *moduleFS) ReadDir(path string) ([]string, error) {
+	var names []string
+	if m.memory.IsDir(path) {
+		memNames, err := m.memory.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, memNames...)
+	}
+	if m.onDisk(path) {
+		diskNames, err := m.disk.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range diskNames {
+			if m.onDisk(filepath.Join(path, name)) && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	} else if err := m.checkDisk(path); err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (m *moduleFS) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	if m.memory.Exists(path) {
+		return m.memory.CleanedAbs(path)
+	}
+	if err := m.checkDisk(path); err != nil {
+		return "", "", err
+	}
+	return m.disk.CleanedAbs(path)
+}
+
+func (m *moduleFS) Exists(path string) bool {
+	return m.memory.Exists(path) || (m.onDisk(path) && m.disk.Exists(path))
+}
+
+func (m *moduleFS) Glob(pattern string) ([]string, error) {
+	matches, err := m.memory.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	diskMatches, err := m.disk.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range diskMatches {
+		if m.onDisk(match) && !slices.Contains(matches, match) {
+			matches = append(matches, match)
+		}
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+func (m *moduleFS) ReadFile(path string) ([]byte, error) {
+	if m.memory.Exists(path) {
+		return m.memory.ReadFile(path)
+	}
+	if err := m.checkDisk(path); err != nil {
+		return nil, err
+	}
+	return m.disk.ReadFile(path)
+}
+
+// Walk walks the files on disk, leaving out those that can't be read.
+func (m *moduleFS) Walk(path string, walkFn filepath.WalkFunc) error {
+	if err := m.checkDisk(path); err != nil {
+		return err
+	}
+	return m.disk.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !m.onDisk(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return walkFn(path, info, err)
+	})
+}
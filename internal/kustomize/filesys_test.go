@@ -0,0 +1,84 @@
+package kustomize
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleFS(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "module")
+	dir := filepath.Join(root, "app")
+	for path, data := range map[string]string{
+		"module/app/kustomization.yaml": "resources: [not-used.yaml]",
+		"module/app/patch.yaml":         "patch",
+		"module/base/deployment.yaml":   "base",
+		"outside/secret.yaml":           "secret",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(tmp, filepath.Dir(path)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(tmp, path), []byte(data), 0o644))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(tmp, "outside"), filepath.Join(root, "link")))
+
+	m, err := newModuleFS(dir, root, map[string][]byte{"kustomization.yaml": []byte("generated")})
+	require.NoError(t, err)
+
+	t.Run("memory files hide files on disk", func(t *testing.T) {
+		data, err := m.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "generated", string(data))
+	})
+
+	t.Run("files within the root are read from disk", func(t *testing.T) {
+		data, err := m.ReadFile(filepath.Join(dir, "../base/deployment.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "base", string(data))
+		assert.True(t, m.IsDir(filepath.Join(root, "base")))
+	})
+
+	t.Run("files outside the root can't be read", func(t *testing.T) {
+		for _, path := range []string{
+			filepath.Join(tmp, "outside/secret.yaml"),
+			filepath.Join(root, "link/secret.yaml"),
+		} {
+			_, err := m.ReadFile(path)
+			require.ErrorContains(t, err, "is outside the module root")
+			assert.False(t, m.Exists(path))
+		}
+		assert.False(t, m.IsDir(filepath.Join(root, "link")))
+	})
+
+	t.Run("kustomization files on disk are hidden", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yml"), []byte("hidden"), 0o644))
+		assert.False(t, m.Exists(filepath.Join(dir, "kustomization.yml")))
+		_, err := m.ReadFile(filepath.Join(dir, "kustomization.yml"))
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("directories list memory and disk files", func(t *testing.T) {
+		names, err := m.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kustomization.yaml", "patch.yaml"}, names)
+	})
+
+	t.Run("nothing can be written", func(t *testing.T) {
+		require.ErrorIs(t, m.WriteFile(filepath.Join(dir, "new.yaml"), nil), errReadOnly)
+		require.ErrorIs(t, m.MkdirAll(filepath.Join(dir, "new")), errReadOnly)
+		require.ErrorIs(t, m.RemoveAll(dir), errReadOnly)
+	})
+}
+
+func TestNewModuleFS_DirOutsideRoot(t *testing.T) {
+	tmp := t.TempDir()
+	_, err := newModuleFS(tmp, filepath.Join(tmp, "."), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmp, "root"), 0o755))
+	_, err = newModuleFS(tmp, filepath.Join(tmp, "root"), nil)
+	require.ErrorContains(t, err, "is outside the module root")
+}
@@ -0,0 +1,116 @@
+// Package kustomize runs kustomizations over generated manifests.
+package kustomize
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	kustomize_types "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// objectsFile is the file the manifests are written to, which is added to the resources of
+// the kustomization.
+const objectsFile = "kogen_objects.yaml"
+
+// Build runs kustomization over manifests, a yaml stream of objects, and returns the resulting
+// yaml stream. The manifests are added to the resources of the kustomization, after any that
+// it already has.
+//
+// When dir is set, the kustomization runs as if it was in dir, so that the components, bases
+// and files it references by path are read from disk relative to dir. Files outside of root
+// can't be read, and nothing is written to disk. Otherwise only the manifests are available.
+func Build(
+	manifests []byte,
+	kustomization kustomize_types.Kustomization,
+	dir, root string,
+) ([]byte, error) {
+	kustomization.Resources = append(kustomization.Resources, objectsFile)
+
+	kustBytes, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kustomization: %w", err)
+	}
+
+	files := map[string][]byte{
+		objectsFile:          manifests,
+		"kustomization.yaml": kustBytes,
+	}
+
+	fSys, path, err := newFileSystem(files, dir, root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Paths are restricted to the module root by the filesystem instead.
+	return run(fSys, path, kustomize_types.LoadRestrictionsNone)
+}
+
+// BuildDir builds the kustomization in the directory at dir, which can reference files
+// anywhere within root but not outside of it.
+func BuildDir(dir, root string) ([]byte, error) {
+	if root == "" {
+		root = dir
+	}
+	fSys, err := newModuleFS(dir, root, nil)
+	if err != nil {
+		return nil, err
+	}
+	return run(fSys, fSys.dir, kustomize_types.LoadRestrictionsNone)
+}
+
+// BuildRemote builds a remote kustomization, such as a directory in a git repository, which
+// kustomize downloads into a temporary directory. The kustomization can only reference files
+// within the download.
+func BuildRemote(url string) ([]byte, error) {
+	return run(filesys.MakeFsOnDisk(), url, kustomize_types.LoadRestrictionsRootOnly)
+}
+
+// IsRemote returns whether path is a remote kustomization that kustomize downloads, rather
+// than a local directory.
+func IsRemote(path string) bool {
+	return strings.Contains(path, "://") || strings.HasPrefix(path, "git@")
+}
+
+// run runs the kustomization at path and returns its output as a yaml stream.
+func run(fSys filesys.FileSystem, path string, restrictions kustomize_types.LoadRestrictions) ([]byte, error) {
+	options := krusty.MakeDefaultOptions()
+	options.LoadRestrictions = restrictions
+
+	kustomizer := krusty.MakeKustomizer(options)
+	resmap, err := kustomizer.Run(fSys, path)
+	if err != nil {
+		return nil, fmt.Errorf("when running kustomize: %w", err)
+	}
+
+	output, err := resmap.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("when marshaling kustomize output: %w", err)
+	}
+	return output, nil
+}
+
+// newFileSystem returns the filesystem to run a kustomization in, with files in the directory
+// at the returned path.
+func newFileSystem(files map[string][]byte, dir, root string) (filesys.FileSystem, string, error) {
+	if dir == "" {
+		memfs := filesys.MakeFsInMemory()
+		for name, data := range files {
+			if err := memfs.WriteFile(name, data); err != nil {
+				return nil, "", fmt.Errorf("failed to write %s to kustomize memfs: %w", name, err)
+			}
+		}
+		return memfs, ".", nil
+	}
+
+	if root == "" {
+		root = dir
+	}
+	fSys, err := newModuleFS(dir, root, files)
+	if err != nil {
+		return nil, "", err
+	}
+	return fSys, fSys.dir, nil
+}
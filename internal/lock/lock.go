@@ -0,0 +1,73 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultPath is the default path of the lock file.
+const DefaultPath = "kogen.lock"
+
+// File pins the digest of every remote artifact used by a build, keyed by artifact name.
+type File struct {
+	Artifacts map[string]string `json:"artifacts"`
+}
+
+// New creates an empty lock file.
+func New() *File {
+	return &File{Artifacts: map[string]string{}}
+}
+
+// Read reads the lock file at path. It returns nil without an error if the file doesn't exist.
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("when reading lock file %s: %w", path, err)
+	}
+
+	f := New()
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, fmt.Errorf("when parsing lock file %s: %w", path, err)
+	}
+	if f.Artifacts == nil {
+		f.Artifacts = map[string]string{}
+	}
+
+	return f, nil
+}
+
+// Write writes the lock file to path.
+func (f *File) Write(path string) error {
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("when writing lock file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Verify returns an error if the digest of an artifact doesn't match the lock file, or if the
+// artifact isn't in it.
+func (f *File) Verify(name, digest string) error {
+	locked, ok := f.Artifacts[name]
+	if !ok {
+		return fmt.Errorf("%s is not in the lock file, run kogen lock to add it", name)
+	}
+
+	if locked != digest {
+		return fmt.Errorf("digest of %s is %s, but the lock file has %s", name, digest, locked)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+
+	f, err := Read(path)
+	require.NoError(t, err)
+	assert.Nil(t, f, "missing lock file")
+
+	f = New()
+	f.Artifacts["resource:https://example.com/b.yaml"] = "sha256:bb"
+	f.Artifacts["helm:https://example.com/charts/a@1.0.0"] = "sha256:aa"
+	require.NoError(t, f.Write(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `artifacts:
+  helm:https://example.com/charts/a@1.0.0: sha256:aa
+  resource:https://example.com/b.yaml: sha256:bb
+`, string(data))
+
+	read, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, f, read)
+}
+
+func TestRead_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	require.NoError(t, os.WriteFile(path, []byte("charts: []\n"), 0o644))
+
+	_, err := Read(path)
+	require.ErrorContains(t, err, "when parsing lock file")
+}
+
+func TestVerify(t *testing.T) {
+	f := New()
+	f.Artifacts["resource:a"] = "sha256:aa"
+
+	tests := map[string]struct {
+		name        string
+		digest      string
+		expectError string
+	}{
+		"matching digest": {
+			name:   "resource:a",
+			digest: "sha256:aa",
+		},
+		"different digest": {
+			name:        "resource:a",
+			digest:      "sha256:bb",
+			expectError: "digest of resource:a is sha256:bb, but the lock file has sha256:aa",
+		},
+		"not locked": {
+			name:        "resource:b",
+			digest:      "sha256:bb",
+			expectError: "resource:b is not in the lock file",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := f.Verify(tc.name, tc.digest)
+			if tc.expectError != "" {
+				require.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+//go:build ignore
+
+// generate downloads the OpenAPI schemas of every supported Kubernetes minor version and writes
+// their definitions, without descriptions, to a gzipped json file per version in the schemas
+// directory. The schemas are taken from the Kubernetes module through the Go module proxy. It is
+// run by go generate in the validate package.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const (
+	firstMinor = 27
+	lastMinor  = 35
+)
+
+func main() {
+	for minor := firstMinor; minor <= lastMinor; minor++ {
+		if err := generate(minor); err != nil {
+			log.Fatalf("when generating schemas of Kubernetes 1.%d: %v", minor, err)
+		}
+	}
+}
+
+func generate(minor int) error {
+	release := fmt.Sprintf("v1.%d.0", minor)
+	swagger, err := download(release)
+	if err != nil {
+		return err
+	}
+
+	var spec struct {
+		Definitions map[string]interface{} `json:"definitions"`
+	}
+	if err := json.Unmarshal(swagger, &spec); err != nil {
+		return fmt.Errorf("when decoding swagger.json: %w", err)
+	}
+
+	definitions := stripDescriptions(spec.Definitions)
+	data, err := json.Marshal(map[string]interface{}{"definitions": definitions})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join("schemas", fmt.Sprintf("1.%d.json.gz", minor)), buf.Bytes(), 0o644)
+}
+
+// download returns the swagger.json of a Kubernetes release.
+func download(release string) ([]byte, error) {
+	url := "https://proxy.golang.org/k8s.io/kubernetes/@v/" + release + ".zip"
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("when downloading %s: %s", url, resp.Status)
+	}
+
+	module, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(module), int64(len(module)))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := archive.Open("k8s.io/kubernetes@" + release + "/api/openapi-spec/swagger.json")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// stripDescriptions removes descriptions, which make up most of the schemas but aren't used
+// for validation. Properties named description are kept, as their values aren't strings.
+func stripDescriptions(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if _, ok := v.(string); k != "description" || !ok {
+				out[k] = stripDescriptions(v)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, v := range value {
+			out[i] = stripDescriptions(v)
+		}
+		return out
+	default:
+		return value
+	}
+}
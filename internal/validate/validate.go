@@ -0,0 +1,325 @@
+package validate
+
+//go:generate go run ./schemas/generate.go
+
+import (
+	"cmp"
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	openapi_validate "k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// schemaFiles are the OpenAPI schemas of each bundled Kubernetes minor version, which are
+// generated by schemas/generate.go.
+//
+//go:embed schemas/*.json.gz
+var schemaFiles embed.FS
+
+const (
+	schemaExtension           = ".json.gz"
+	groupVersionKindExtension = "x-kubernetes-group-version-kind"
+)
+
+// CRDGroupKind is the GroupKind of a CustomResourceDefinition.
+var CRDGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// Validator validates objects against the schemas of built-in Kubernetes types, and the schemas
+// of any CustomResourceDefinitions added to it. The schemas of built-in types are bundled with
+// kogen for every Kubernetes minor version from 1.27 to 1.35.
+type Validator struct {
+	// releases are the schemas of each bundled Kubernetes minor version, keyed by version. They
+	// are nil until first used, as parsing them is slow.
+	releases map[string]*release
+	// releaseNames are the bundled Kubernetes minor versions, oldest first.
+	releaseNames []string
+	crdSchemas   map[schema.GroupVersionKind]*spec.Schema
+}
+
+// release contains the schemas of the built-in types of a Kubernetes minor version.
+type release struct {
+	typeConverter managedfields.TypeConverter
+	kinds         map[schema.GroupVersionKind]bool
+	groups        map[string]bool
+}
+
+// NewValidator creates a new validator.
+func NewValidator() *Validator {
+	v := &Validator{
+		releases:   map[string]*release{},
+		crdSchemas: map[schema.GroupVersionKind]*spec.Schema{},
+	}
+
+	files, err := fs.Glob(schemaFiles, "schemas/*"+schemaExtension)
+	utilruntime.Must(err)
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), schemaExtension)
+		v.releases[name] = nil
+		v.releaseNames = append(v.releaseNames, name)
+	}
+	slices.SortFunc(v.releaseNames, func(a, b string) int {
+		return cmp.Compare(version.MustParseGeneric(a).Minor(), version.MustParseGeneric(b).Minor())
+	})
+
+	return v
+}
+
+// AddCRD adds the schema of every version of a CustomResourceDefinition to the validator.
+func (v *Validator) AddCRD(obj *unstructured.Unstructured) error {
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &crd); err != nil {
+		return fmt.Errorf("when decoding CustomResourceDefinition %s: %w", obj.GetName(), err)
+	}
+
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Schema == nil || crdVersion.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+
+		// The CRD schema is a subset of OpenAPI v3, so it can be converted through json.
+		schemaBytes, err := json.Marshal(crdVersion.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return fmt.Errorf("when encoding schema of CustomResourceDefinition %s: %w", crd.Name, err)
+		}
+
+		var s spec.Schema
+		if err := json.Unmarshal(schemaBytes, &s); err != nil {
+			return fmt.Errorf("when decoding schema of CustomResourceDefinition %s: %w", crd.Name, err)
+		}
+
+		gvk := schema.GroupVersionKind{
+			Group:   crd.Spec.Group,
+			Version: crdVersion.Name,
+			Kind:    crd.Spec.Names.Kind,
+		}
+		v.crdSchemas[gvk] = &s
+	}
+
+	return nil
+}
+
+// Validate returns every schema violation found in obj. kubeVersion is the Kubernetes
+// version the object is deployed to, which selects the schemas of built-in types. The latest
+// bundled version is used when it's empty.
+//
+// Objects of kinds that are neither built-in nor defined by a known CRD are not validated.
+func (v *Validator) Validate(obj *unstructured.Unstructured, kubeVersion string) []error {
+	gvk := obj.GroupVersionKind()
+
+	if crdSchema, ok := v.crdSchemas[gvk]; ok {
+		return validateCustomResource(obj, crdSchema)
+	}
+
+	name, r, err := v.release(kubeVersion)
+	if err != nil {
+		return []error{err}
+	}
+
+	if r.kinds[gvk] {
+		if _, err := r.typeConverter.ObjectToTyped(obj); err != nil {
+			return splitErrors(err)
+		}
+		return nil
+	}
+
+	if r.groups[gvk.Group] {
+		return []error{fmt.Errorf(
+			"no schema for %s %s, it is not a built-in type of Kubernetes %s",
+			gvk.GroupVersion(),
+			gvk.Kind,
+			name,
+		)}
+	}
+
+	return nil
+}
+
+// release returns the name and schemas of the bundled Kubernetes minor version of kubeVersion.
+func (v *Validator) release(kubeVersion string) (string, *release, error) {
+	name := v.releaseNames[len(v.releaseNames)-1]
+	if kubeVersion != "" {
+		parsedVersion, err := version.ParseGeneric(kubeVersion)
+		if err != nil {
+			return "", nil, fmt.Errorf("when parsing kubeVersion %q: %w", kubeVersion, err)
+		}
+		name = fmt.Sprintf("%d.%d", parsedVersion.Major(), parsedVersion.Minor())
+	}
+
+	r, bundled := v.releases[name]
+	if !bundled {
+		return "", nil, fmt.Errorf(
+			"no schemas for kubeVersion %s, kogen has schemas for Kubernetes %s to %s",
+			kubeVersion,
+			v.releaseNames[0],
+			v.releaseNames[len(v.releaseNames)-1],
+		)
+	}
+
+	if r == nil {
+		var err error
+		if r, err = loadRelease(name); err != nil {
+			return "", nil, fmt.Errorf("when loading schemas of Kubernetes %s: %w", name, err)
+		}
+		v.releases[name] = r
+	}
+
+	return name, r, nil
+}
+
+// loadRelease parses the bundled schemas of a Kubernetes minor version.
+func loadRelease(name string) (*release, error) {
+	f, err := schemaFiles.Open("schemas/" + name + schemaExtension)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var openAPISpec struct {
+		Definitions map[string]*spec.Schema `json:"definitions"`
+	}
+	if err := json.NewDecoder(gz).Decode(&openAPISpec); err != nil {
+		return nil, err
+	}
+
+	typeConverter, err := managedfields.NewTypeConverter(openAPISpec.Definitions, false)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &release{
+		typeConverter: typeConverter,
+		kinds:         map[schema.GroupVersionKind]bool{},
+		groups:        map[string]bool{},
+	}
+	for _, definition := range openAPISpec.Definitions {
+		gvks, _ := definition.Extensions[groupVersionKindExtension].([]interface{})
+		for _, gvk := range gvks {
+			gvk, _ := gvk.(map[string]interface{})
+			group, _ := gvk["group"].(string)
+			groupVersion, _ := gvk["version"].(string)
+			kind, _ := gvk["kind"].(string)
+			r.kinds[schema.GroupVersionKind{Group: group, Version: groupVersion, Kind: kind}] = true
+			r.groups[group] = true
+		}
+	}
+
+	return r, nil
+}
+
+// splitErrors splits an error made up of one error per line into separate errors.
+func splitErrors(err error) []error {
+	var errs []error
+	for line := range strings.SplitSeq(err.Error(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "errors:" {
+			continue
+		}
+		errs = append(errs, errors.New(line))
+	}
+	return errs
+}
+
+// validateCustomResource validates a custom resource against its CRD schema.
+func validateCustomResource(obj *unstructured.Unstructured, crdSchema *spec.Schema) []error {
+	var errs []error
+
+	result := openapi_validate.NewSchemaValidator(crdSchema, nil, "", strfmt.Default).
+		Validate(obj.Object)
+	errs = append(errs, result.Errors...)
+
+	// The api server prunes fields that aren't in the schema rather than rejecting them, which
+	// hides typos. They are reported as errors instead. Fields every object has are skipped.
+	for _, key := range slices.Sorted(maps.Keys(obj.Object)) {
+		if key == "apiVersion" || key == "kind" || key == "metadata" {
+			continue
+		}
+		errs = append(errs, unknownFields(crdSchema, key, obj.Object[key], "."+key)...)
+	}
+
+	return errs
+}
+
+// unknownFields returns an error for each field in value that isn't declared in the schema of
+// the property named field.
+func unknownFields(parent *spec.Schema, field string, value interface{}, path string) []error {
+	s, known := propertySchema(parent, field)
+	if !known {
+		return []error{fmt.Errorf("%s: field not declared in schema", path)}
+	}
+	if s == nil || preservesUnknownFields(s) {
+		return nil
+	}
+
+	var errs []error
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, key := range slices.Sorted(maps.Keys(value)) {
+			errs = append(errs, unknownFields(s, key, value[key], path+"."+key)...)
+		}
+	case []interface{}:
+		if s.Items == nil || s.Items.Schema == nil {
+			return nil
+		}
+		for i, item := range value {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			itemMap, ok := item.(map[string]interface{})
+			if !ok || preservesUnknownFields(s.Items.Schema) {
+				continue
+			}
+			for _, key := range slices.Sorted(maps.Keys(itemMap)) {
+				errs = append(errs, unknownFields(s.Items.Schema, key, itemMap[key], itemPath+"."+key)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+// propertySchema returns the schema of a property of parent, and whether the property is
+// allowed. A nil schema means the property is allowed but its value isn't checked.
+func propertySchema(parent *spec.Schema, field string) (*spec.Schema, bool) {
+	if preservesUnknownFields(parent) {
+		return nil, true
+	}
+
+	if s, ok := parent.Properties[field]; ok {
+		return &s, true
+	}
+
+	if parent.AdditionalProperties != nil {
+		if parent.AdditionalProperties.Schema != nil {
+			return parent.AdditionalProperties.Schema, true
+		}
+		return nil, parent.AdditionalProperties.Allows
+	}
+
+	return nil, false
+}
+
+func preservesUnknownFields(s *spec.Schema) bool {
+	preserve, _ := s.Extensions.GetBool("x-kubernetes-preserve-unknown-fields")
+	embedded, _ := s.Extensions.GetBool("x-kubernetes-embedded-resource")
+	return preserve || embedded
+}
@@ -0,0 +1,240 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const crdYaml = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [size]
+            properties:
+              size:
+                type: integer
+                minimum: 1
+              labels:
+                type: object
+                additionalProperties:
+                  type: string
+              items:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    name:
+                      type: string
+              extra:
+                type: object
+                x-kubernetes-preserve-unknown-fields: true
+`
+
+func mustUnstructured(t *testing.T, data string) *unstructured.Unstructured {
+	t.Helper()
+	u := &unstructured.Unstructured{}
+	require.NoError(t, yaml.Unmarshal([]byte(data), &u.Object))
+	return u
+}
+
+func errorStrings(errs []error) []string {
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return out
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		object      string
+		kubeVersion string
+		expected    []string
+	}{
+		"valid deployment": {
+			object: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+`,
+		},
+		"unknown field in built-in type": {
+			object: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replica: 2
+`,
+			expected: []string{".spec.replica: field not declared in schema"},
+		},
+		"wrong type in built-in type": {
+			object: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: eighty
+`,
+			expected: []string{`.spec.ports[port="eighty"].port: expected numeric (int or float), got string`},
+		},
+		"field added in a later version": {
+			object: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  trafficDistribution: PreferClose
+`,
+			kubeVersion: "v1.29.4",
+			expected:    []string{".spec.trafficDistribution: field not declared in schema"},
+		},
+		"field in the version it was added": {
+			object: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  trafficDistribution: PreferClose
+`,
+			kubeVersion: "1.30",
+		},
+		"removed apiVersion": {
+			object: `
+apiVersion: flowcontrol.apiserver.k8s.io/v1beta2
+kind: FlowSchema
+metadata:
+  name: schema
+`,
+			kubeVersion: "v1.29.0",
+			expected:    []string{"no schema for flowcontrol.apiserver.k8s.io/v1beta2 FlowSchema, it is not a built-in type of Kubernetes 1.29"},
+		},
+		"apiVersion before removal": {
+			object: `
+apiVersion: flowcontrol.apiserver.k8s.io/v1beta2
+kind: FlowSchema
+metadata:
+  name: schema
+spec:
+  priorityLevelConfiguration:
+    name: global
+`,
+			kubeVersion: "1.28",
+		},
+		"unknown version of built-in group": {
+			object: `
+apiVersion: apps/v1beta9
+kind: Deployment
+metadata:
+  name: web
+`,
+			expected: []string{"no schema for apps/v1beta9 Deployment, it is not a built-in type of Kubernetes 1.35"},
+		},
+		"kubeVersion without bundled schemas": {
+			object: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+			kubeVersion: "1.24.0",
+			expected:    []string{"no schemas for kubeVersion 1.24.0, kogen has schemas for Kubernetes 1.27 to 1.35"},
+		},
+		"invalid kubeVersion": {
+			object: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+			kubeVersion: "latest",
+			expected:    []string{`when parsing kubeVersion "latest": could not parse "latest" as version`},
+		},
+		"unknown custom resource is skipped": {
+			object: `
+apiVersion: other.example.com/v1
+kind: Gadget
+metadata:
+  name: g
+spec:
+  anything: true
+`,
+		},
+		"valid custom resource": {
+			object: `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  size: 2
+  labels:
+    a: b
+  items:
+  - name: one
+  extra:
+    anything: true
+`,
+		},
+		"invalid custom resource": {
+			object: `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  size: 0
+  sise: 2
+  items:
+  - nmae: one
+status:
+  ready: true
+`,
+			expected: []string{
+				"spec.size in body should be greater than or equal to 1",
+				".spec.items[0].nmae: field not declared in schema",
+				".spec.sise: field not declared in schema",
+				".status: field not declared in schema",
+			},
+		},
+	}
+
+	validator := NewValidator()
+	require.NoError(t, validator.AddCRD(mustUnstructured(t, crdYaml)))
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			errs := validator.Validate(mustUnstructured(t, tc.object), tc.kubeVersion)
+			assert.Equal(t, tc.expected, errorStrings(errs))
+		})
+	}
+}